@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx"
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
+)
+
+// operator is allowed everything: the commands are run by whoever runs the
+// server.
+func operator(db *pgx.ConnPool) uscases.PermissionServiceImpl {
+	return uscases.NewPermissionServiceImpl(repositories.NewRoleRepoImpl(db)).AsOperator()
+}
+
+func status(args []string, connect Connector) error {
+	flags := newFlagSet("status")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err = expectArgs(positional, 0, "status [--json]"); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stat := uscases.NewUserUsecaseImpl(repositories.NewUserRepoImpl(db)).GetServerStatus()
+
+	return printResult(*asJSON, stat,
+		[]string{"users", strconv.Itoa(stat.User)},
+		[]string{"forums", strconv.Itoa(stat.Forum)},
+		[]string{"threads", strconv.Itoa(stat.Thread)},
+		[]string{"posts", strconv.FormatInt(stat.Post, 10)})
+}
+
+func userFields(user models.UserModel) [][]string {
+	return [][]string{
+		{"nickname", user.Nickname},
+		{"fullname", user.Fullname},
+		{"email", user.Email},
+		{"about", user.About},
+	}
+}
+
+func user(args []string, connect Connector) error {
+	if len(args) == 0 {
+		return errors.New("usage: user create|show|update nickname")
+	}
+
+	flags := newFlagSet("user " + args[0])
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	fullname := flags.String("fullname", "", "full name")
+	email := flags.String("email", "", "e-mail")
+	about := flags.String("about", "", "about")
+	positional, err := parseArgs(flags, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if err = expectArgs(positional, 1, "user "+args[0]+" nickname [--fullname name] [--email email] [--about text] [--json]"); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	userUse := uscases.NewUserUsecaseImpl(repositories.NewUserRepoImpl(db))
+	userData := models.UserModel{Nickname: positional[0], Fullname: *fullname, Email: *email, About: *about}
+
+	switch args[0] {
+	case "create":
+		if userData.Fullname == "" || userData.Email == "" {
+			return errors.New("--fullname and --email are required")
+		}
+
+		answer, err := userUse.CreateUser(userData)
+		if _, ok := err.(validation.Errors); ok {
+			return errors.New("invalid user: " + err.Error())
+		}
+
+		if err != nil {
+			return errors.New("user with this nickname or email already exists")
+		}
+		userData = answer.(models.UserModel)
+
+	case "show":
+		userData, err = userUse.GetUser(userData.Nickname)
+		if err != nil {
+			return errors.New("can't find user by nickname: " + positional[0])
+		}
+
+	case "update":
+		userData, err = userUse.UpdateUserData(userData)
+		if err != nil {
+			return errors.New("can't update user " + positional[0] + ": " + err.Error())
+		}
+
+	default:
+		return errors.New("unknown user command " + args[0])
+	}
+
+	return printResult(*asJSON, userData, userFields(userData)...)
+}
+
+func forum(args []string, connect Connector) error {
+	if len(args) == 0 {
+		return errors.New("usage: forum create|show slug")
+	}
+
+	flags := newFlagSet("forum " + args[0])
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	title := flags.String("title", "", "forum title")
+	owner := flags.String("user", "", "nickname of the forum owner")
+	positional, err := parseArgs(flags, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if err = expectArgs(positional, 1, "forum "+args[0]+" slug [--title title] [--user nickname] [--json]"); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	forumUse := uscases.NewForumUsecaseImpl(repositories.NewForumRepoImpl(db), operator(db))
+	forumData := models.Forum{Slug: positional[0], Title: *title, User: *owner}
+
+	switch args[0] {
+	case "create":
+		if forumData.Title == "" || forumData.User == "" {
+			return errors.New("--title and --user are required")
+		}
+
+		forumData, err = forumUse.CreateForum(forumData)
+		if err != nil {
+			return errors.New("can't create forum " + positional[0] + ": " + err.Error())
+		}
+
+	case "show":
+		forumData, err = forumUse.GetForumData(forumData.Slug)
+		if err != nil {
+			return errors.New("can't find forum by slug: " + positional[0])
+		}
+
+	default:
+		return errors.New("unknown forum command " + args[0])
+	}
+
+	return printResult(*asJSON, forumData,
+		[]string{"slug", forumData.Slug},
+		[]string{"title", forumData.Title},
+		[]string{"user", forumData.User},
+		[]string{"threads", strconv.Itoa(forumData.Threads)},
+		[]string{"posts", strconv.FormatInt(forumData.Posts, 10)})
+}
+
+func thread(args []string, connect Connector) error {
+	if len(args) == 0 || args[0] != "lock" {
+		return errors.New("usage: thread lock slug_or_id [--unlock]")
+	}
+
+	flags := newFlagSet("thread lock")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	unlock := flags.Bool("unlock", false, "allow posting again")
+	positional, err := parseArgs(flags, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if err = expectArgs(positional, 1, "thread lock slug_or_id [--unlock] [--json]"); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	threadUse := uscases.NewThreadsUsecaseImpl(repositories.NewThreadRepoImpl(db), operator(db))
+	threadData, err := threadUse.LockThread(positional[0], "", !*unlock)
+	if err != nil {
+		return errors.New("can't find thread by slug_or_id: " + positional[0])
+	}
+
+	return printResult(*asJSON, threadData,
+		[]string{"id", strconv.Itoa(threadData.Id)},
+		[]string{"slug", threadData.Slug},
+		[]string{"title", threadData.Title},
+		[]string{"forum", threadData.Forum},
+		[]string{"author", threadData.Author},
+		[]string{"created", threadData.Created.Format(time.RFC3339)},
+		[]string{"locked", strconv.FormatBool(threadData.Locked)})
+}
+
+func clearData(args []string, connect Connector) error {
+	flags := newFlagSet("clear")
+	slug := flags.String("forum", "", "only delete this forum with its threads and posts")
+	yes := flags.Bool("yes", false, "confirm deleting the data")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err = expectArgs(positional, 0, "clear [--forum slug] --yes"); err != nil {
+		return err
+	}
+
+	if !*yes {
+		return errors.New("refusing to delete data without --yes")
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *slug == "" {
+		uscases.NewUserUsecaseImpl(repositories.NewUserRepoImpl(db)).Clear()
+		return nil
+	}
+
+	err = uscases.NewForumUsecaseImpl(repositories.NewForumRepoImpl(db), operator(db)).ClearForum(*slug)
+	if err != nil {
+		return errors.New("can't find forum by slug: " + *slug)
+	}
+
+	return nil
+}
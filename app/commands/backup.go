@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"errors"
+	"os"
+
+	"vk_db_project/app/repositories"
+	"vk_db_project/app/uscases"
+)
+
+func backup(args []string, connect Connector) error {
+	flags := newFlagSet("backup")
+	out := flags.String("out", "", "file to write the backup to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return errors.New("--out is required")
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+
+	backupUse := uscases.NewBackupUsecaseImpl(repositories.NewBackupRepoImpl(db), repositories.NewUserRepoImpl(db))
+	counts, err := backupUse.Backup(file)
+	if err != nil {
+		file.Close()
+		os.Remove(*out)
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	printCounts(counts)
+	return nil
+}
+
+func restore(args []string, connect Connector) error {
+	flags := newFlagSet("restore")
+	in := flags.String("in", "", "backup file to restore from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *in == "" {
+		return errors.New("--in is required")
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	backupUse := uscases.NewBackupUsecaseImpl(repositories.NewBackupRepoImpl(db), repositories.NewUserRepoImpl(db))
+	counts, err := backupUse.Restore(file)
+	if err != nil {
+		return err
+	}
+
+	printCounts(counts)
+	return nil
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jackc/pgx"
+)
+
+type Connector func() (*pgx.ConnPool, error)
+
+type command struct {
+	usage string
+	run   func([]string, Connector) error
+}
+
+var commandList = map[string]command{
+	"backup":  {usage: "backup --out file", run: backup},
+	"restore": {usage: "restore --in file", run: restore},
+	"import":  {usage: "import mbox --forum slug file.mbox", run: importData},
+	"status":  {usage: "status [--json]", run: status},
+	"user":    {usage: "user create|show|update nickname [--fullname name] [--email email] [--about text] [--json]", run: user},
+	"forum":   {usage: "forum create|show slug [--title title] [--user nickname] [--json]", run: forum},
+	"thread":  {usage: "thread lock slug_or_id [--unlock] [--json]", run: thread},
+	"clear":   {usage: "clear [--forum slug] --yes", run: clearData},
+}
+
+// Run executes the subcommand named by args[0] and returns the exit code.
+// The serve command is handled by main, which owns the HTTP server setup.
+func Run(args []string, connect Connector) int {
+	cmd, ok := commandList[args[0]]
+	if !ok {
+		usage()
+		return 2
+	}
+
+	if err := cmd.run(args[1:], connect); err != nil {
+		fmt.Fprintln(os.Stderr, args[0]+":", err)
+		return 1
+	}
+
+	return 0
+}
+
+func usage() {
+	names := make([]string, 0, len(commandList))
+	for name := range commandList {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  main [serve]")
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  main", commandList[name].usage)
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+func printCounts(counts map[string]int64) {
+	tables := make([]string, 0, len(counts))
+	for table := range counts {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		fmt.Printf("%-12s %d\n", table, counts[table])
+	}
+}
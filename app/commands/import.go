@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"vk_db_project/app/repositories"
+	"vk_db_project/app/uscases"
+)
+
+func importData(args []string, connect Connector) error {
+	if len(args) == 0 || args[0] != "mbox" {
+		return errors.New("only \"import mbox\" is supported")
+	}
+
+	flags := newFlagSet("import mbox")
+	forum := flags.String("forum", "", "slug of the forum to import into")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *forum == "" || flags.NArg() != 1 {
+		return errors.New("usage: import mbox --forum slug file.mbox")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	importUse := uscases.NewImportUsecaseImpl(repositories.NewImportRepoImpl(db), repositories.NewUserRepoImpl(db),
+		repositories.NewForumRepoImpl(db), repositories.NewThreadRepoImpl(db))
+
+	result, err := importUse.ImportMbox(*forum, file)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("users %d, threads %d, posts %d, skipped %d, rejected %d\n", result.Users, result.Threads, result.Posts, result.Skipped, result.Rejected)
+	return nil
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// parseArgs parses flags that may come before or after positional arguments,
+// so both "user show --json bob" and "user show bob --json" work.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func expectArgs(positional []string, count int, usage string) error {
+	if len(positional) != count {
+		return errors.New("usage: " + usage)
+	}
+
+	return nil
+}
+
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
+
+func printTable(header []string, rows ...[]string) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, strings.ToUpper(strings.Join(header, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	return writer.Flush()
+}
+
+// printResult prints value as JSON or as a two column field/value table.
+func printResult(asJSON bool, value interface{}, fields ...[]string) error {
+	if asJSON {
+		return printJSON(value)
+	}
+
+	return printTable([]string{"field", "value"}, fields...)
+}
@@ -6,4 +6,18 @@ import (
 
 var (
 	AlreadyExists = errors.New("such already exist")
+	InvalidUrl    = errors.New("webhook url must be an absolute http(s) url")
+	InvalidEvent  = errors.New("unknown webhook event")
+	UnknownFormat = errors.New("format must be raw or html")
+
+	UnknownExportFormat = errors.New("format must be json, markdown or html")
+	ThreadLocked        = errors.New("thread is locked")
+	VersionMismatch     = errors.New("resource was modified, reload it and retry")
+	NotSubscribed       = errors.New("user is not subscribed to the thread")
+	ParentNotFound      = errors.New("can't find parent forum")
+	ForumCycle          = errors.New("forum can't be moved under itself or its sub-forums")
+	Forbidden           = errors.New("not allowed to do this in the forum")
+	Banned              = errors.New("user is banned in the forum")
+	OwnerRole           = errors.New("the owner role can't be granted or revoked")
+	UserNotFound        = errors.New("can't find user")
 )
@@ -0,0 +1,490 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: forum.proto
+
+package forumpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Forum_CreateUser_FullMethodName     = "/forum.Forum/CreateUser"
+	Forum_GetUser_FullMethodName        = "/forum.Forum/GetUser"
+	Forum_CreateForum_FullMethodName    = "/forum.Forum/CreateForum"
+	Forum_CreateThread_FullMethodName   = "/forum.Forum/CreateThread"
+	Forum_CreatePosts_FullMethodName    = "/forum.Forum/CreatePosts"
+	Forum_VoteThread_FullMethodName     = "/forum.Forum/VoteThread"
+	Forum_GetPostsSorted_FullMethodName = "/forum.Forum/GetPostsSorted"
+	Forum_GetPost_FullMethodName        = "/forum.Forum/GetPost"
+	Forum_UpdatePost_FullMethodName     = "/forum.Forum/UpdatePost"
+	Forum_Status_FullMethodName         = "/forum.Forum/Status"
+)
+
+// ForumClient is the client API for Forum service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ForumClient interface {
+	// POST /api/user/{nickname}/create
+	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error)
+	// GET /api/user/{nickname}/profile
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
+	// POST /api/forum/create
+	CreateForum(ctx context.Context, in *CreateForumRequest, opts ...grpc.CallOption) (*ForumInfo, error)
+	// POST /api/forum/{slug}/create
+	CreateThread(ctx context.Context, in *CreateThreadRequest, opts ...grpc.CallOption) (*Thread, error)
+	// POST /api/thread/{slug_or_id}/create
+	CreatePosts(ctx context.Context, in *CreatePostsRequest, opts ...grpc.CallOption) (*CreatePostsResponse, error)
+	// POST /api/thread/{slug_or_id}/vote
+	VoteThread(ctx context.Context, in *VoteThreadRequest, opts ...grpc.CallOption) (*Thread, error)
+	// GET /api/thread/{slug_or_id}/posts, one message per post
+	GetPostsSorted(ctx context.Context, in *GetPostsSortedRequest, opts ...grpc.CallOption) (Forum_GetPostsSortedClient, error)
+	// GET /api/post/{id}/details
+	GetPost(ctx context.Context, in *GetPostRequest, opts ...grpc.CallOption) (*PostDetails, error)
+	// POST /api/post/{id}/details, the actor is read from the "actor" metadata key
+	UpdatePost(ctx context.Context, in *UpdatePostRequest, opts ...grpc.CallOption) (*Post, error)
+	// GET /api/service/status
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusInfo, error)
+}
+
+type forumClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewForumClient(cc grpc.ClientConnInterface) ForumClient {
+	return &forumClient{cc}
+}
+
+func (c *forumClient) CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, Forum_CreateUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, Forum_GetUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumClient) CreateForum(ctx context.Context, in *CreateForumRequest, opts ...grpc.CallOption) (*ForumInfo, error) {
+	out := new(ForumInfo)
+	err := c.cc.Invoke(ctx, Forum_CreateForum_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumClient) CreateThread(ctx context.Context, in *CreateThreadRequest, opts ...grpc.CallOption) (*Thread, error) {
+	out := new(Thread)
+	err := c.cc.Invoke(ctx, Forum_CreateThread_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumClient) CreatePosts(ctx context.Context, in *CreatePostsRequest, opts ...grpc.CallOption) (*CreatePostsResponse, error) {
+	out := new(CreatePostsResponse)
+	err := c.cc.Invoke(ctx, Forum_CreatePosts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumClient) VoteThread(ctx context.Context, in *VoteThreadRequest, opts ...grpc.CallOption) (*Thread, error) {
+	out := new(Thread)
+	err := c.cc.Invoke(ctx, Forum_VoteThread_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumClient) GetPostsSorted(ctx context.Context, in *GetPostsSortedRequest, opts ...grpc.CallOption) (Forum_GetPostsSortedClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Forum_ServiceDesc.Streams[0], Forum_GetPostsSorted_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &forumGetPostsSortedClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Forum_GetPostsSortedClient interface {
+	Recv() (*Post, error)
+	grpc.ClientStream
+}
+
+type forumGetPostsSortedClient struct {
+	grpc.ClientStream
+}
+
+func (x *forumGetPostsSortedClient) Recv() (*Post, error) {
+	m := new(Post)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *forumClient) GetPost(ctx context.Context, in *GetPostRequest, opts ...grpc.CallOption) (*PostDetails, error) {
+	out := new(PostDetails)
+	err := c.cc.Invoke(ctx, Forum_GetPost_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumClient) UpdatePost(ctx context.Context, in *UpdatePostRequest, opts ...grpc.CallOption) (*Post, error) {
+	out := new(Post)
+	err := c.cc.Invoke(ctx, Forum_UpdatePost_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusInfo, error) {
+	out := new(StatusInfo)
+	err := c.cc.Invoke(ctx, Forum_Status_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ForumServer is the server API for Forum service.
+// All implementations must embed UnimplementedForumServer
+// for forward compatibility
+type ForumServer interface {
+	// POST /api/user/{nickname}/create
+	CreateUser(context.Context, *CreateUserRequest) (*User, error)
+	// GET /api/user/{nickname}/profile
+	GetUser(context.Context, *GetUserRequest) (*User, error)
+	// POST /api/forum/create
+	CreateForum(context.Context, *CreateForumRequest) (*ForumInfo, error)
+	// POST /api/forum/{slug}/create
+	CreateThread(context.Context, *CreateThreadRequest) (*Thread, error)
+	// POST /api/thread/{slug_or_id}/create
+	CreatePosts(context.Context, *CreatePostsRequest) (*CreatePostsResponse, error)
+	// POST /api/thread/{slug_or_id}/vote
+	VoteThread(context.Context, *VoteThreadRequest) (*Thread, error)
+	// GET /api/thread/{slug_or_id}/posts, one message per post
+	GetPostsSorted(*GetPostsSortedRequest, Forum_GetPostsSortedServer) error
+	// GET /api/post/{id}/details
+	GetPost(context.Context, *GetPostRequest) (*PostDetails, error)
+	// POST /api/post/{id}/details, the actor is read from the "actor" metadata key
+	UpdatePost(context.Context, *UpdatePostRequest) (*Post, error)
+	// GET /api/service/status
+	Status(context.Context, *StatusRequest) (*StatusInfo, error)
+	mustEmbedUnimplementedForumServer()
+}
+
+// UnimplementedForumServer must be embedded to have forward compatible implementations.
+type UnimplementedForumServer struct {
+}
+
+func (UnimplementedForumServer) CreateUser(context.Context, *CreateUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateUser not implemented")
+}
+func (UnimplementedForumServer) GetUser(context.Context, *GetUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedForumServer) CreateForum(context.Context, *CreateForumRequest) (*ForumInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateForum not implemented")
+}
+func (UnimplementedForumServer) CreateThread(context.Context, *CreateThreadRequest) (*Thread, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateThread not implemented")
+}
+func (UnimplementedForumServer) CreatePosts(context.Context, *CreatePostsRequest) (*CreatePostsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePosts not implemented")
+}
+func (UnimplementedForumServer) VoteThread(context.Context, *VoteThreadRequest) (*Thread, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VoteThread not implemented")
+}
+func (UnimplementedForumServer) GetPostsSorted(*GetPostsSortedRequest, Forum_GetPostsSortedServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetPostsSorted not implemented")
+}
+func (UnimplementedForumServer) GetPost(context.Context, *GetPostRequest) (*PostDetails, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPost not implemented")
+}
+func (UnimplementedForumServer) UpdatePost(context.Context, *UpdatePostRequest) (*Post, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePost not implemented")
+}
+func (UnimplementedForumServer) Status(context.Context, *StatusRequest) (*StatusInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedForumServer) mustEmbedUnimplementedForumServer() {}
+
+// UnsafeForumServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ForumServer will
+// result in compilation errors.
+type UnsafeForumServer interface {
+	mustEmbedUnimplementedForumServer()
+}
+
+func RegisterForumServer(s grpc.ServiceRegistrar, srv ForumServer) {
+	s.RegisterService(&Forum_ServiceDesc, srv)
+}
+
+func _Forum_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).CreateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_CreateUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).CreateUser(ctx, req.(*CreateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Forum_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_GetUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Forum_CreateForum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateForumRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).CreateForum(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_CreateForum_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).CreateForum(ctx, req.(*CreateForumRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Forum_CreateThread_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateThreadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).CreateThread(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_CreateThread_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).CreateThread(ctx, req.(*CreateThreadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Forum_CreatePosts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePostsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).CreatePosts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_CreatePosts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).CreatePosts(ctx, req.(*CreatePostsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Forum_VoteThread_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VoteThreadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).VoteThread(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_VoteThread_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).VoteThread(ctx, req.(*VoteThreadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Forum_GetPostsSorted_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetPostsSortedRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ForumServer).GetPostsSorted(m, &forumGetPostsSortedServer{stream})
+}
+
+type Forum_GetPostsSortedServer interface {
+	Send(*Post) error
+	grpc.ServerStream
+}
+
+type forumGetPostsSortedServer struct {
+	grpc.ServerStream
+}
+
+func (x *forumGetPostsSortedServer) Send(m *Post) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Forum_GetPost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).GetPost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_GetPost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).GetPost(ctx, req.(*GetPostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Forum_UpdatePost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).UpdatePost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_UpdatePost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).UpdatePost(ctx, req.(*UpdatePostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Forum_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forum_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Forum_ServiceDesc is the grpc.ServiceDesc for Forum service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Forum_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "forum.Forum",
+	HandlerType: (*ForumServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateUser",
+			Handler:    _Forum_CreateUser_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _Forum_GetUser_Handler,
+		},
+		{
+			MethodName: "CreateForum",
+			Handler:    _Forum_CreateForum_Handler,
+		},
+		{
+			MethodName: "CreateThread",
+			Handler:    _Forum_CreateThread_Handler,
+		},
+		{
+			MethodName: "CreatePosts",
+			Handler:    _Forum_CreatePosts_Handler,
+		},
+		{
+			MethodName: "VoteThread",
+			Handler:    _Forum_VoteThread_Handler,
+		},
+		{
+			MethodName: "GetPost",
+			Handler:    _Forum_GetPost_Handler,
+		},
+		{
+			MethodName: "UpdatePost",
+			Handler:    _Forum_UpdatePost_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Forum_Status_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetPostsSorted",
+			Handler:       _Forum_GetPostsSorted_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "forum.proto",
+}
@@ -0,0 +1,4 @@
+// Package forumpb holds the gRPC API generated from forum.proto.
+package forumpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative forum.proto
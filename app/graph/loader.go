@@ -0,0 +1,167 @@
+package graph
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"vk_db_project/app/models"
+)
+
+// loader is a per-request dataloader. Load only records the key and returns a
+// thunk; graphql-go resolves thunks after the whole level of the query has
+// been walked, so the first thunk fetches every key collected so far in one
+// call and the rest are answered from the cache.
+type loader struct {
+	fetch     func([]string) (map[string]interface{}, error)
+	normalize func(string) string
+
+	mutex   sync.Mutex
+	pending map[string]bool
+	cache   map[string]interface{}
+	errs    map[string]error
+}
+
+func newLoader(fetch func([]string) (map[string]interface{}, error), normalize func(string) string) *loader {
+	return &loader{
+		fetch:     fetch,
+		normalize: normalize,
+		pending:   make(map[string]bool),
+		cache:     make(map[string]interface{}),
+		errs:      make(map[string]error),
+	}
+}
+
+func (Loader *loader) Load(key string) func() (interface{}, error) {
+	key = Loader.normalize(key)
+
+	Loader.mutex.Lock()
+	if _, ok := Loader.cache[key]; !ok && Loader.errs[key] == nil {
+		Loader.pending[key] = true
+	}
+	Loader.mutex.Unlock()
+
+	return func() (interface{}, error) {
+		Loader.mutex.Lock()
+		defer Loader.mutex.Unlock()
+
+		Loader.flush()
+
+		if err := Loader.errs[key]; err != nil {
+			return nil, err
+		}
+
+		return Loader.cache[key], nil
+	}
+}
+
+// Prime stores a value that is already known, e.g. a thread just returned by
+// a mutation, so resolving it again costs no query.
+func (Loader *loader) Prime(key string, value interface{}) {
+	key = Loader.normalize(key)
+
+	Loader.mutex.Lock()
+	Loader.cache[key] = value
+	delete(Loader.pending, key)
+	Loader.mutex.Unlock()
+}
+
+func (Loader *loader) flush() {
+	if len(Loader.pending) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(Loader.pending))
+	for key := range Loader.pending {
+		keys = append(keys, key)
+	}
+	Loader.pending = make(map[string]bool)
+
+	values, err := Loader.fetch(keys)
+	for _, key := range keys {
+		if err != nil {
+			Loader.errs[key] = err
+			continue
+		}
+
+		// Keys without a row resolve to null instead of being fetched again.
+		Loader.cache[key] = values[key]
+	}
+}
+
+type loaders struct {
+	users   *loader
+	forums  *loader
+	threads *loader
+}
+
+type loadersKey struct{}
+
+// WithLoaders attaches fresh loaders to the context of one GraphQL request.
+// They must not outlive it: the cache is never invalidated.
+func (Graph Resolver) WithLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		users:   newLoader(Graph.fetchUsers, strings.ToLower),
+		forums:  newLoader(Graph.fetchForums, strings.ToLower),
+		threads: newLoader(Graph.fetchThreads, func(key string) string { return key }),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+func (Graph Resolver) fetchUsers(nicknames []string) (map[string]interface{}, error) {
+	users, err := Graph.userLogic.GetUsers(nicknames)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(users))
+	for _, user := range users {
+		values[strings.ToLower(user.Nickname)] = user
+	}
+
+	return values, nil
+}
+
+func (Graph Resolver) fetchForums(slugs []string) (map[string]interface{}, error) {
+	forums, err := Graph.forumLogic.GetForums(slugs)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(forums))
+	for _, forum := range forums {
+		values[strings.ToLower(forum.Slug)] = forum
+	}
+
+	return values, nil
+}
+
+func (Graph Resolver) fetchThreads(keys []string) (map[string]interface{}, error) {
+	ids := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	threads, err := Graph.threadLogic.GetThreadsByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(threads))
+	for _, thread := range threads {
+		values[strconv.Itoa(thread.Id)] = thread
+	}
+
+	return values, nil
+}
+
+func primeThread(ctx context.Context, thread models.Thread) {
+	loadersFrom(ctx).threads.Prime(strconv.Itoa(thread.Id), thread)
+}
@@ -0,0 +1,351 @@
+// Package graph exposes the forum as a GraphQL schema. Resolvers call the same
+// usecases as the REST handlers; authors, forums and threads referenced from
+// lists are fetched through per-request loaders to avoid N+1 queries.
+package graph
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jackc/pgx"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+)
+
+const defaultLimit = 100
+
+type Resolver struct {
+	userLogic   uscases.IUserUsecase
+	forumLogic  uscases.IForumUsecase
+	threadLogic uscases.IThreadUsecase
+	postLogic   uscases.IPostUsecase
+}
+
+func NewResolver(uLogic uscases.UserUsecaseImpl, fLogic uscases.ForumUsecaseImpl, tLogic uscases.ThreadsUsecaseImpl, pLogic uscases.PostUsecaseImpl) Resolver {
+	return Resolver{userLogic: uLogic, forumLogic: fLogic, threadLogic: tLogic, postLogic: pLogic}
+}
+
+func (Graph Resolver) Schema() (graphql.Schema, error) {
+	formatEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Format",
+		Values: graphql.EnumValueConfigMap{
+			uscases.FormatRaw:  &graphql.EnumValueConfig{Value: uscases.FormatRaw},
+			uscases.FormatHtml: &graphql.EnumValueConfig{Value: uscases.FormatHtml},
+		},
+	})
+
+	sortEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "PostSort",
+		Values: graphql.EnumValueConfigMap{
+			"flat":        &graphql.EnumValueConfig{Value: "flat"},
+			"tree":        &graphql.EnumValueConfig{Value: "tree"},
+			"parent_tree": &graphql.EnumValueConfig{Value: "parent_tree"},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"nickname": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"fullname": &graphql.Field{Type: graphql.String},
+			"email":    &graphql.Field{Type: graphql.String},
+			"about":    &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	var forumType, threadType, postType *graphql.Object
+
+	forumType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Forum",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"slug":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"title":  &graphql.Field{Type: graphql.String},
+				"parent": &graphql.Field{Type: graphql.String},
+				"user": &graphql.Field{Type: userType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).users.Load(p.Source.(models.Forum).User), nil
+				}},
+				"postCount": &graphql.Field{Type: graphql.Int, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Forum).Posts, nil
+				}},
+				"threadCount": &graphql.Field{Type: graphql.Int, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Forum).Threads, nil
+				}},
+				"threads": &graphql.Field{
+					Type: graphql.NewList(graphql.NewNonNull(threadType)),
+					Args: graphql.FieldConfigArgument{
+						"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultLimit},
+						"since": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+						"desc":  &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					},
+					Resolve: Graph.resolveForumThreads,
+				},
+				"users": &graphql.Field{
+					Type: graphql.NewList(graphql.NewNonNull(userType)),
+					Args: graphql.FieldConfigArgument{
+						"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultLimit},
+						"since": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+						"desc":  &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					},
+					Resolve: Graph.resolveForumUsers,
+				},
+			}
+		}),
+	})
+
+	threadType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Thread",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"slug":    &graphql.Field{Type: graphql.String},
+				"title":   &graphql.Field{Type: graphql.String},
+				"message": &graphql.Field{Type: graphql.String},
+				"votes":   &graphql.Field{Type: graphql.Int},
+				"locked":  &graphql.Field{Type: graphql.Boolean},
+				"tags":    &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				"created": &graphql.Field{Type: graphql.DateTime},
+				"author": &graphql.Field{Type: userType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).users.Load(p.Source.(models.Thread).Author), nil
+				}},
+				"forum": &graphql.Field{Type: forumType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).forums.Load(p.Source.(models.Thread).Forum), nil
+				}},
+				"posts": &graphql.Field{
+					Type: graphql.NewList(graphql.NewNonNull(postType)),
+					Args: graphql.FieldConfigArgument{
+						"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultLimit},
+						"since":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+						"sort":   &graphql.ArgumentConfig{Type: sortEnum, DefaultValue: "flat"},
+						"desc":   &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+						"format": &graphql.ArgumentConfig{Type: formatEnum, DefaultValue: uscases.FormatRaw},
+					},
+					Resolve: Graph.resolveThreadPosts,
+				},
+			}
+		}),
+	})
+
+	postType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Post",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"parent":   &graphql.Field{Type: graphql.Int},
+				"message":  &graphql.Field{Type: graphql.String},
+				"isEdited": &graphql.Field{Type: graphql.Boolean},
+				"created":  &graphql.Field{Type: graphql.DateTime},
+				"mentions": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				"author": &graphql.Field{Type: userType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).users.Load(p.Source.(models.Post).Author), nil
+				}},
+				"forum": &graphql.Field{Type: forumType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).forums.Load(p.Source.(models.Post).Forum), nil
+				}},
+				"thread": &graphql.Field{Type: threadType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).threads.Load(strconv.Itoa(p.Source.(models.Post).Thread)), nil
+				}},
+			}
+		}),
+	})
+
+	postInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PostInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"author":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"message": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"parent":  &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: 0},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type:    userType,
+				Args:    graphql.FieldConfigArgument{"nickname": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: Graph.resolveUser,
+			},
+			"forum": &graphql.Field{
+				Type:    forumType,
+				Args:    graphql.FieldConfigArgument{"slug": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: Graph.resolveForum,
+			},
+			"thread": &graphql.Field{
+				Type: threadType,
+				Args: graphql.FieldConfigArgument{
+					"slugOrId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"format":   &graphql.ArgumentConfig{Type: formatEnum, DefaultValue: uscases.FormatRaw},
+				},
+				Resolve: Graph.resolveThread,
+			},
+			"post": &graphql.Field{
+				Type: postType,
+				Args: graphql.FieldConfigArgument{
+					"id":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"format": &graphql.ArgumentConfig{Type: formatEnum, DefaultValue: uscases.FormatRaw},
+				},
+				Resolve: Graph.resolvePost,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createPosts": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(postType)),
+				Args: graphql.FieldConfigArgument{
+					"thread": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"posts":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postInput)))},
+				},
+				Resolve: Graph.resolveCreatePosts,
+			},
+			"voteThread": &graphql.Field{
+				Type: threadType,
+				Args: graphql.FieldConfigArgument{
+					"thread":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"nickname": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"voice":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: Graph.resolveVoteThread,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func (Graph Resolver) resolveUser(p graphql.ResolveParams) (interface{}, error) {
+	nickname := p.Args["nickname"].(string)
+
+	user, err := Graph.userLogic.GetUser(nickname)
+	if err != nil {
+		return nil, errors.New("Can't find user by nickname: " + nickname)
+	}
+
+	return user, nil
+}
+
+func (Graph Resolver) resolveForum(p graphql.ResolveParams) (interface{}, error) {
+	slug := p.Args["slug"].(string)
+
+	forum, err := Graph.forumLogic.GetForumData(slug)
+	if err != nil {
+		return nil, errors.New("Can't find forum by slug: " + slug)
+	}
+
+	return forum, nil
+}
+
+func (Graph Resolver) resolveThread(p graphql.ResolveParams) (interface{}, error) {
+	slugOrId := p.Args["slugOrId"].(string)
+
+	thread, err := Graph.threadLogic.GetThread(slugOrId, p.Args["format"].(string))
+	if err == pgx.ErrNoRows {
+		return nil, errors.New("Can't find thread by slug_or_id: " + slugOrId)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return thread, nil
+}
+
+func (Graph Resolver) resolvePost(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(int)
+
+	data, err := Graph.postLogic.GetPostData(id, nil, p.Args["format"].(string), models.RelatedOptions{})
+	if err == pgx.ErrNoRows {
+		return nil, errors.New("can't find post by id: " + strconv.Itoa(id))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return *data.Post, nil
+}
+
+func (Graph Resolver) resolveForumThreads(p graphql.ResolveParams) (interface{}, error) {
+	forum := p.Source.(models.Forum)
+
+	return Graph.forumLogic.GetThreads(forum.Slug, p.Args["limit"].(int), p.Args["since"].(string), p.Args["desc"].(bool), models.ThreadFilter{})
+}
+
+func (Graph Resolver) resolveForumUsers(p graphql.ResolveParams) (interface{}, error) {
+	forum := p.Source.(models.Forum)
+
+	users, err := Graph.forumLogic.GetForumUsers(forum.Slug, p.Args["limit"].(int), p.Args["since"].(string), p.Args["desc"].(bool))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		loadersFrom(p.Context).users.Prime(user.Nickname, user)
+	}
+
+	return users, nil
+}
+
+func (Graph Resolver) resolveThreadPosts(p graphql.ResolveParams) (interface{}, error) {
+	thread := p.Source.(models.Thread)
+	primeThread(p.Context, thread)
+
+	return Graph.threadLogic.GetPosts(strconv.Itoa(thread.Id), p.Args["limit"].(int), p.Args["since"].(int),
+		p.Args["sort"].(string), p.Args["desc"].(bool), p.Args["format"].(string))
+}
+
+func (Graph Resolver) resolveCreatePosts(p graphql.ResolveParams) (interface{}, error) {
+	slugOrId := p.Args["thread"].(string)
+
+	input := p.Args["posts"].([]interface{})
+	posts := make([]models.Post, 0, len(input))
+	for _, item := range input {
+		fields := item.(map[string]interface{})
+		posts = append(posts, models.Post{
+			Author:  fields["author"].(string),
+			Message: fields["message"].(string),
+			Parent:  int64(fields["parent"].(int)),
+		})
+	}
+
+	posts, err := Graph.threadLogic.CreatePosts(slugOrId, posts)
+	if err == pgx.ErrNoRows {
+		return nil, errors.New("can't find thread by slug_or_id: " + slugOrId)
+	}
+
+	if err == forumErrors.ThreadLocked {
+		return nil, err
+	}
+
+	if err != nil && err.Error() == "no user" {
+		return nil, errors.New("Can't find post author by nickname: ")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (Graph Resolver) resolveVoteThread(p graphql.ResolveParams) (interface{}, error) {
+	slugOrId := p.Args["thread"].(string)
+
+	thread, err := Graph.threadLogic.VoteThread(slugOrId, p.Args["nickname"].(string), p.Args["voice"].(int))
+	if err == forumErrors.Banned {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, errors.New("can't vote by slug_or_id:" + slugOrId)
+	}
+
+	primeThread(p.Context, thread)
+
+	return thread, nil
+}
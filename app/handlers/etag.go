@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+)
+
+const (
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// versionETag builds a weak ETag from a row version and any other counters
+// that are part of the representation, e.g. W/"3.12" or W/"3.12-html".
+// The row version always comes first so If-Match can recover it.
+func versionETag(format string, version int64, counters ...int64) string {
+	tag := strconv.FormatInt(version, 10)
+	for _, counter := range counters {
+		tag += "." + strconv.FormatInt(counter, 10)
+	}
+
+	if format != "" && format != uscases.FormatRaw {
+		tag += "-" + format
+	}
+
+	return "W/\"" + tag + "\""
+}
+
+// postsETag hashes the id and version of every post of a listing.
+func postsETag(format string, posts []models.Post) string {
+	hash := sha1.New()
+	hash.Write([]byte(format))
+	for _, post := range posts {
+		hash.Write([]byte(":" + strconv.FormatInt(post.Id, 10) + "." + strconv.FormatInt(post.Version, 10)))
+	}
+
+	return "W/\"" + hex.EncodeToString(hash.Sum(nil)[:10]) + "\""
+}
+
+func opaqueTag(etag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimSpace(candidate) == "*" || opaqueTag(candidate) == opaqueTag(etag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified sets the ETag header and reports whether the client already
+// has this representation, in which case the caller answers 304.
+func notModified(rwContext echo.Context, etag string) bool {
+	rwContext.Response().Header().Set(headerETag, etag)
+
+	header := rwContext.Request().Header.Get(headerIfNoneMatch)
+
+	return header != "" && etagMatches(header, etag)
+}
+
+// expectedVersion reads If-Match for an update. Our ETags are weak, so the
+// comparison is weak too; only the row version is compared, so a vote does
+// not make a pending edit of the same thread fail. ok is false when the
+// header cannot match any version and the request must get 412.
+func expectedVersion(rwContext echo.Context) (version int64, ok bool) {
+	header := strings.TrimSpace(rwContext.Request().Header.Get(headerIfMatch))
+	if header == "" || header == "*" {
+		return models.AnyVersion, true
+	}
+
+	tag := strings.Trim(opaqueTag(header), "\"")
+	if end := strings.IndexAny(tag, ".-"); end >= 0 {
+		tag = tag[:end]
+	}
+
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+
+	return version, true
+}
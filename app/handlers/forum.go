@@ -6,8 +6,10 @@ import (
 
 	"github.com/jackc/pgx"
 	"github.com/labstack/echo"
+	forumErrors "vk_db_project/app/errors"
 	"vk_db_project/app/models"
 	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
 )
 
 type ForumHandler struct {
@@ -23,6 +25,14 @@ func (ForumHandler ForumHandler) CreateForum(rwContext echo.Context) error {
 
 	rwContext.Bind(newForumData)
 	answer, err := ForumHandler.ForumLogic.CreateForum(*newForumData)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == forumErrors.ParentNotFound {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + newForumData.Parent})
+	}
+
 	if err == pgx.ErrNoRows {
 		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + newForumData.User})
 	}
@@ -48,6 +58,26 @@ func (ForumHandler ForumHandler) GetForumUsers(rwContext echo.Context) error {
 	return rwContext.JSON(http.StatusOK, data)
 }
 
+func (ForumHandler ForumHandler) GetLeaderboard(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
+
+	entries, err := ForumHandler.ForumLogic.GetLeaderboard(slug, rwContext.QueryParam("metric"), rwContext.QueryParam("period"), limit)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + slug})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, entries)
+}
+
 func (ForumHandler ForumHandler) GetForum(rwContext echo.Context) error {
 	slug := rwContext.Param("slug")
 
@@ -57,6 +87,10 @@ func (ForumHandler ForumHandler) GetForum(rwContext echo.Context) error {
 		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + slug})
 	}
 
+	if notModified(rwContext, versionETag("", answer.Version)) {
+		return rwContext.NoContent(http.StatusNotModified)
+	}
+
 	return rwContext.JSON(http.StatusOK, answer)
 }
 
@@ -67,10 +101,18 @@ func (ForumHandler ForumHandler) CreateThread(rwContext echo.Context) error {
 	rwContext.Bind(threadReq)
 
 	thread, err := ForumHandler.ForumLogic.CreateThread(slug, *threadReq)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
 	if err == pgx.ErrNoRows {
 		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't create thread by slug: " + slug})
 	}
 
+	if err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, &models.Error{Message: err.Error()})
+	}
+
 	if err != nil {
 		return rwContext.JSON(http.StatusConflict, thread)
 	}
@@ -78,13 +120,23 @@ func (ForumHandler ForumHandler) CreateThread(rwContext echo.Context) error {
 	return rwContext.JSON(http.StatusCreated, thread)
 }
 
+// headerNextSince carries the since cursor of the next page of a ranked list.
+const headerNextSince = "X-Next-Since"
+
 func (ForumHandler ForumHandler) GetSortedThreads(rwContext echo.Context) error {
 	slug := rwContext.Param("slug")
+	if sort := rwContext.QueryParam("sort"); sort != "" && sort != models.SortNew {
+		return ForumHandler.getRankedThreads(rwContext, slug)
+	}
+
 	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
 	since := rwContext.QueryParam("since")
 	desc, _ := strconv.ParseBool(rwContext.QueryParam("desc"))
 
-	threads, err := ForumHandler.ForumLogic.GetThreads(slug, limit, since, desc)
+	threads, err := ForumHandler.ForumLogic.GetThreads(slug, limit, since, desc, threadFilter(rwContext))
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
 
 	if err != nil {
 		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't create thread by slug: " + slug})
@@ -93,10 +145,149 @@ func (ForumHandler ForumHandler) GetSortedThreads(rwContext echo.Context) error
 	return rwContext.JSON(http.StatusOK, threads)
 }
 
+// GetTrending is sort=hot (by default) over all forums.
+func (ForumHandler ForumHandler) GetTrending(rwContext echo.Context) error {
+	return ForumHandler.getRankedThreads(rwContext, "")
+}
+
+func (ForumHandler ForumHandler) getRankedThreads(rwContext echo.Context, slug string) error {
+	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
+
+	threads, next, err := ForumHandler.ForumLogic.GetRankedThreads(slug, rwContext.QueryParam("sort"), rwContext.QueryParam("period"), limit, rwContext.QueryParam("since"), threadFilter(rwContext))
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + slug})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	if next != "" {
+		rwContext.Response().Header().Set(headerNextSince, next)
+	}
+
+	return rwContext.JSON(http.StatusOK, threads)
+}
+
+// GetActivity serves both a forum's stats and, without a slug, the stats of
+// all forums.
+func (ForumHandler ForumHandler) GetActivity(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+	query := rwContext.QueryParams()
+
+	stats, err := ForumHandler.ForumLogic.GetActivity(slug, query.Get("from"), query.Get("to"), query.Get("bucket"))
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + slug})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, stats)
+}
+
+// threadFilter reads repeated tag params, tagMode and recursive.
+func threadFilter(rwContext echo.Context) models.ThreadFilter {
+	recursive, _ := strconv.ParseBool(rwContext.QueryParam("recursive"))
+
+	return models.ThreadFilter{Tags: rwContext.QueryParams()["tag"], Mode: rwContext.QueryParam("tagMode"), Recursive: recursive}
+}
+
+func (ForumHandler ForumHandler) GetTags(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
+
+	tags, err := ForumHandler.ForumLogic.GetTags(slug, limit)
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + slug})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, tags)
+}
+
+func (ForumHandler ForumHandler) GetChildren(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	forums, err := ForumHandler.ForumLogic.GetChildren(slug)
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + slug})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, forums)
+}
+
+type forumMoveRequest struct {
+	Parent string `json:"parent"`
+}
+
+// MoveForum takes {"parent": slug}; a null or empty parent makes the forum
+// top level.
+func (ForumHandler ForumHandler) MoveForum(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	request := new(forumMoveRequest)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	forum, err := ForumHandler.ForumLogic.MoveForum(slug, rwContext.QueryParam("actor"), request.Parent)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + slug})
+	}
+
+	if err == forumErrors.ParentNotFound {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find forum by slug: " + request.Parent})
+	}
+
+	if err == forumErrors.ForumCycle {
+		return rwContext.JSON(http.StatusConflict, &models.Error{Message: err.Error()})
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, &models.Error{Message: err.Error()})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	rwContext.Response().Header().Set(headerETag, versionETag("", forum.Version))
+
+	return rwContext.JSON(http.StatusOK, forum)
+}
+
 func (ForumHandler ForumHandler) SetupHandlers(server *echo.Echo) {
 	server.POST("/api/forum/create", ForumHandler.CreateForum)
 	server.GET("/api/forum/:slug/details", ForumHandler.GetForum)
 	server.POST("/api/forum/:slug/create", ForumHandler.CreateThread)
 	server.GET("/api/forum/:slug/threads", ForumHandler.GetSortedThreads)
 	server.GET("/api/forum/:slug/users", ForumHandler.GetForumUsers)
+	server.GET("/api/forum/:slug/leaderboard", ForumHandler.GetLeaderboard)
+	server.GET("/api/forum/:slug/stats", ForumHandler.GetActivity)
+	server.GET("/api/forum/:slug/tags", ForumHandler.GetTags)
+	server.GET("/api/forum/:slug/children", ForumHandler.GetChildren)
+	server.POST("/api/forum/:slug/move", ForumHandler.MoveForum)
+	server.GET("/api/service/stats", ForumHandler.GetActivity)
+	server.GET("/api/threads/trending", ForumHandler.GetTrending)
 }
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/labstack/echo"
+	"vk_db_project/app/graph"
+	"vk_db_project/app/models"
+)
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type GraphqlHandler struct {
+	resolver graph.Resolver
+	schema   graphql.Schema
+}
+
+// NewGraphqlHandler panics if the schema does not build: that is a programming
+// error and should stop the server at startup.
+func NewGraphqlHandler(resolver graph.Resolver) GraphqlHandler {
+	schema, err := resolver.Schema()
+	if err != nil {
+		panic(err)
+	}
+
+	return GraphqlHandler{resolver: resolver, schema: schema}
+}
+
+func (Graphql GraphqlHandler) Execute(rwContext echo.Context) error {
+	request := new(graphqlRequest)
+
+	if rwContext.Request().Method == http.MethodGet {
+		request.Query = rwContext.QueryParam("query")
+		request.OperationName = rwContext.QueryParam("operationName")
+	} else if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, &models.Error{Message: err.Error()})
+	}
+
+	if request.Query == "" {
+		return rwContext.JSON(http.StatusBadRequest, &models.Error{Message: "query is required"})
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         Graphql.schema,
+		RequestString:  request.Query,
+		OperationName:  request.OperationName,
+		VariableValues: request.Variables,
+		Context:        Graphql.resolver.WithLoaders(rwContext.Request().Context()),
+	})
+
+	return rwContext.JSON(http.StatusOK, result)
+}
+
+func (Graphql GraphqlHandler) SetupHandlers(server *echo.Echo) {
+	server.GET("/graphql", Graphql.Execute)
+	server.POST("/graphql", Graphql.Execute)
+}
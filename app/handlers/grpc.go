@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/forumpb"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
+)
+
+// GrpcHandler serves forum.proto on top of the same usecases as the REST
+// handlers. Every method answers with the code matching the HTTP status the
+// corresponding route would return.
+type GrpcHandler struct {
+	forumpb.UnimplementedForumServer
+
+	userLogic   uscases.IUserUsecase
+	forumLogic  uscases.IForumUsecase
+	threadLogic uscases.IThreadUsecase
+	postLogic   uscases.IPostUsecase
+}
+
+func NewGrpcHandler(uLogic uscases.UserUsecaseImpl, fLogic uscases.ForumUsecaseImpl, tLogic uscases.ThreadsUsecaseImpl, pLogic uscases.PostUsecaseImpl) *GrpcHandler {
+	return &GrpcHandler{userLogic: uLogic, forumLogic: fLogic, threadLogic: tLogic, postLogic: pLogic}
+}
+
+func (Grpc *GrpcHandler) SetupHandlers(server *grpc.Server) {
+	forumpb.RegisterForumServer(server, Grpc)
+}
+
+var httpToGrpcCodes = map[int]codes.Code{
+	http.StatusBadRequest: codes.InvalidArgument,
+	http.StatusForbidden:  codes.PermissionDenied,
+	http.StatusNotFound:   codes.NotFound,
+	http.StatusConflict:   codes.AlreadyExists,
+
+	http.StatusPreconditionFailed: codes.FailedPrecondition,
+}
+
+func grpcError(httpStatus int, message string) error {
+	code, ok := httpToGrpcCodes[httpStatus]
+	if !ok {
+		code = codes.Internal
+	}
+
+	return status.Error(code, message)
+}
+
+func (Grpc *GrpcHandler) CreateUser(ctx context.Context, request *forumpb.CreateUserRequest) (*forumpb.User, error) {
+	answer, err := Grpc.userLogic.CreateUser(userFromPb(request.GetUser()))
+	if _, ok := err.(validation.Errors); ok {
+		return nil, grpcError(http.StatusBadRequest, err.Error())
+	}
+
+	if err != nil {
+		return nil, grpcError(http.StatusConflict, "such user already exists")
+	}
+
+	return userToPb(answer.(models.UserModel)), nil
+}
+
+func (Grpc *GrpcHandler) GetUser(ctx context.Context, request *forumpb.GetUserRequest) (*forumpb.User, error) {
+	userData, err := Grpc.userLogic.GetUser(request.GetNickname())
+	if err != nil {
+		return nil, grpcError(http.StatusNotFound, "Can't find user by nickname: "+request.GetNickname())
+	}
+
+	return userToPb(userData), nil
+}
+
+func (Grpc *GrpcHandler) CreateForum(ctx context.Context, request *forumpb.CreateForumRequest) (*forumpb.ForumInfo, error) {
+	forumData := forumFromPb(request.GetForum())
+
+	answer, err := Grpc.forumLogic.CreateForum(forumData)
+	if _, ok := err.(validation.Errors); ok {
+		return nil, grpcError(http.StatusBadRequest, err.Error())
+	}
+
+	if err == pgx.ErrNoRows {
+		return nil, grpcError(http.StatusNotFound, "Can't find user by nickname: "+forumData.User)
+	}
+
+	if err != nil {
+		return nil, grpcError(http.StatusConflict, "forum already exists: "+answer.Slug)
+	}
+
+	return forumToPb(answer), nil
+}
+
+func (Grpc *GrpcHandler) CreateThread(ctx context.Context, request *forumpb.CreateThreadRequest) (*forumpb.Thread, error) {
+	thread, err := Grpc.forumLogic.CreateThread(request.GetForum(), threadFromPb(request.GetThread()))
+	if _, ok := err.(validation.Errors); ok {
+		return nil, grpcError(http.StatusBadRequest, err.Error())
+	}
+
+	if err == pgx.ErrNoRows {
+		return nil, grpcError(http.StatusNotFound, "Can't create thread by slug: "+request.GetForum())
+	}
+
+	if err == forumErrors.Banned {
+		return nil, grpcError(http.StatusForbidden, err.Error())
+	}
+
+	if err != nil {
+		return nil, grpcError(http.StatusConflict, "thread already exists: "+thread.Slug)
+	}
+
+	return threadToPb(thread), nil
+}
+
+func (Grpc *GrpcHandler) CreatePosts(ctx context.Context, request *forumpb.CreatePostsRequest) (*forumpb.CreatePostsResponse, error) {
+	posts := make([]models.Post, 0, len(request.GetPosts()))
+	for _, post := range request.GetPosts() {
+		posts = append(posts, postFromPb(post))
+	}
+
+	posts, err := Grpc.threadLogic.CreatePosts(request.GetSlugOrId(), posts)
+	if _, ok := err.(validation.Errors); ok {
+		return nil, grpcError(http.StatusBadRequest, err.Error())
+	}
+
+	if err == pgx.ErrNoRows {
+		return nil, grpcError(http.StatusNotFound, "can't find thread by slug_or_id: "+request.GetSlugOrId())
+	}
+
+	if err == forumErrors.ThreadLocked || err == forumErrors.Banned {
+		return nil, grpcError(http.StatusForbidden, err.Error())
+	}
+
+	if err != nil && err.Error() == "no user" {
+		return nil, grpcError(http.StatusNotFound, "Can't find post author by nickname: ")
+	}
+
+	if err != nil {
+		return nil, grpcError(http.StatusConflict, err.Error())
+	}
+
+	response := &forumpb.CreatePostsResponse{Posts: make([]*forumpb.Post, 0, len(posts))}
+	for _, post := range posts {
+		response.Posts = append(response.Posts, postToPb(post))
+	}
+
+	return response, nil
+}
+
+func (Grpc *GrpcHandler) VoteThread(ctx context.Context, request *forumpb.VoteThreadRequest) (*forumpb.Thread, error) {
+	thread, err := Grpc.threadLogic.VoteThread(request.GetSlugOrId(), request.GetNickname(), int(request.GetVoice()))
+	if err == forumErrors.Banned {
+		return nil, grpcError(http.StatusForbidden, err.Error())
+	}
+
+	if err != nil {
+		return nil, grpcError(http.StatusNotFound, "can't vote by slug_or_id:"+request.GetSlugOrId())
+	}
+
+	return threadToPb(thread), nil
+}
+
+func (Grpc *GrpcHandler) GetPostsSorted(request *forumpb.GetPostsSortedRequest, stream forumpb.Forum_GetPostsSortedServer) error {
+	sortType := request.GetSort()
+	if sortType == "" {
+		sortType = "flat"
+	}
+
+	posts, err := Grpc.threadLogic.GetPosts(request.GetSlugOrId(), int(request.GetLimit()), int(request.GetSince()), sortType, request.GetDesc(), request.GetFormat())
+	if err == forumErrors.UnknownFormat {
+		return grpcError(http.StatusBadRequest, err.Error())
+	}
+
+	if err != nil {
+		return grpcError(http.StatusNotFound, "can't get thread by slug_or_id:"+request.GetSlugOrId())
+	}
+
+	for _, post := range posts {
+		if err = stream.Send(postToPb(post)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (Grpc *GrpcHandler) GetPost(ctx context.Context, request *forumpb.GetPostRequest) (*forumpb.PostDetails, error) {
+	data, err := Grpc.postLogic.GetPostData(int(request.GetId()), request.GetRelated(), request.GetFormat(), models.RelatedOptions{})
+	if err == forumErrors.UnknownFormat {
+		return nil, grpcError(http.StatusBadRequest, err.Error())
+	}
+
+	if err != nil {
+		return nil, grpcError(http.StatusNotFound, "can't find post by id: "+strconv.FormatInt(request.GetId(), 10))
+	}
+
+	details := &forumpb.PostDetails{}
+	if data.Post != nil {
+		details.Post = postToPb(*data.Post)
+	}
+	if data.Author != nil {
+		details.Author = userToPb(*data.Author)
+	}
+	if data.Thread != nil {
+		details.Thread = threadToPb(*data.Thread)
+	}
+	if data.Forum != nil {
+		details.Forum = forumToPb(*data.Forum)
+	}
+
+	return details, nil
+}
+
+// actorFromContext reads the actor metadata key, the counterpart of the
+// ?actor= query parameter of the REST routes.
+func actorFromContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("actor"); len(values) != 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (Grpc *GrpcHandler) UpdatePost(ctx context.Context, request *forumpb.UpdatePostRequest) (*forumpb.Post, error) {
+	post, err := Grpc.postLogic.UpdatePost(request.GetId(), actorFromContext(ctx), request.GetMessage(), models.AnyVersion)
+	if _, ok := err.(validation.Errors); ok {
+		return nil, grpcError(http.StatusBadRequest, err.Error())
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return nil, grpcError(http.StatusForbidden, err.Error())
+	}
+
+	if err == pgx.ErrNoRows {
+		return nil, grpcError(http.StatusNotFound, "can't find post by id: "+strconv.FormatInt(request.GetId(), 10))
+	}
+
+	if err != nil {
+		return nil, grpcError(http.StatusInternalServerError, err.Error())
+	}
+
+	return postToPb(post), nil
+}
+
+func (Grpc *GrpcHandler) Status(ctx context.Context, request *forumpb.StatusRequest) (*forumpb.StatusInfo, error) {
+	stat := Grpc.userLogic.GetServerStatus()
+
+	return &forumpb.StatusInfo{User: int64(stat.User), Forum: int64(stat.Forum), Thread: int64(stat.Thread), Post: stat.Post}, nil
+}
+
+func userToPb(user models.UserModel) *forumpb.User {
+	return &forumpb.User{Nickname: user.Nickname, Fullname: user.Fullname, Email: user.Email, About: user.About}
+}
+
+func userFromPb(user *forumpb.User) models.UserModel {
+	return models.UserModel{Nickname: user.GetNickname(), Fullname: user.GetFullname(), Email: user.GetEmail(), About: user.GetAbout()}
+}
+
+func forumToPb(forum models.Forum) *forumpb.ForumInfo {
+	return &forumpb.ForumInfo{Slug: forum.Slug, Title: forum.Title, User: forum.User, Posts: forum.Posts, Threads: int64(forum.Threads)}
+}
+
+func forumFromPb(forum *forumpb.ForumInfo) models.Forum {
+	return models.Forum{Slug: forum.GetSlug(), Title: forum.GetTitle(), User: forum.GetUser()}
+}
+
+func threadToPb(thread models.Thread) *forumpb.Thread {
+	return &forumpb.Thread{
+		Id:      int64(thread.Id),
+		Slug:    thread.Slug,
+		Title:   thread.Title,
+		Message: thread.Message,
+		Author:  thread.Author,
+		Forum:   thread.Forum,
+		Votes:   int64(thread.Votes),
+		Created: timestamppb.New(thread.Created),
+	}
+}
+
+func threadFromPb(thread *forumpb.Thread) models.Thread {
+	answer := models.Thread{Slug: thread.GetSlug(), Title: thread.GetTitle(), Message: thread.GetMessage(), Author: thread.GetAuthor()}
+	if thread.GetCreated() != nil {
+		answer.Created = thread.GetCreated().AsTime()
+	}
+
+	return answer
+}
+
+func postToPb(post models.Post) *forumpb.Post {
+	return &forumpb.Post{
+		Id:       post.Id,
+		Parent:   post.Parent,
+		Author:   post.Author,
+		Message:  post.Message,
+		IsEdited: post.IsEdited,
+		Forum:    post.Forum,
+		Thread:   int64(post.Thread),
+		Created:  timestamppb.New(post.Created),
+		Mentions: post.Mentions,
+	}
+}
+
+func postFromPb(post *forumpb.Post) models.Post {
+	return models.Post{Author: post.GetAuthor(), Message: post.GetMessage(), Parent: post.GetParent()}
+}
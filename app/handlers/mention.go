@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx"
+	"github.com/labstack/echo"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+)
+
+type MentionHandler struct {
+	mentionLogic uscases.IMentionUsecase
+}
+
+func NewMentionHandler(mLogic uscases.MentionUsecaseImpl) MentionHandler {
+	return MentionHandler{mentionLogic: mLogic}
+}
+
+func (Mention MentionHandler) GetMentions(rwContext echo.Context) error {
+	nickname := rwContext.Param("nickname")
+	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
+	since, _ := strconv.ParseInt(rwContext.QueryParam("since"), 10, 64)
+
+	posts, err := Mention.mentionLogic.GetMentions(nickname, limit, since)
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, posts)
+}
+
+func (Mention MentionHandler) SetupHandlers(server *echo.Echo) {
+	server.GET("/api/user/:nickname/mentions", Mention.GetMentions)
+}
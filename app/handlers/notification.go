@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx"
+	"github.com/labstack/echo"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+)
+
+type NotificationHandler struct {
+	notificationLogic uscases.INotificationUsecase
+}
+
+func NewNotificationHandler(nLogic uscases.NotificationUsecaseImpl) NotificationHandler {
+	return NotificationHandler{notificationLogic: nLogic}
+}
+
+func (Notification NotificationHandler) GetNotifications(rwContext echo.Context) error {
+	nickname := rwContext.Param("nickname")
+	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
+	since, _ := strconv.ParseInt(rwContext.QueryParam("since"), 10, 64)
+	unread, _ := strconv.ParseBool(rwContext.QueryParam("unread"))
+
+	notifications, err := Notification.notificationLogic.GetNotifications(nickname, limit, since, unread)
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, notifications)
+}
+
+func (Notification NotificationHandler) MarkRead(rwContext echo.Context) error {
+	nickname := rwContext.Param("nickname")
+
+	readReq := new(models.NotificationsRead)
+	rwContext.Bind(readReq)
+
+	answer, err := Notification.notificationLogic.MarkRead(nickname, readReq.UpTo)
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, answer)
+}
+
+func (Notification NotificationHandler) SetupHandlers(server *echo.Echo) {
+	server.GET("/api/user/:nickname/notifications", Notification.GetNotifications)
+	server.POST("/api/user/:nickname/notifications/read", Notification.MarkRead)
+}
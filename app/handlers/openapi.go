@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"vk_db_project/app/models"
+	"vk_db_project/app/openapi"
+)
+
+type OpenapiHandler struct {
+	validator *openapi.Validator
+}
+
+// NewOpenapiHandler panics if the embedded spec does not parse, like
+// NewGraphqlHandler does for the schema.
+func NewOpenapiHandler() OpenapiHandler {
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		panic(err)
+	}
+
+	return OpenapiHandler{validator: validator}
+}
+
+func (Openapi OpenapiHandler) GetSpec(rwContext echo.Context) error {
+	return rwContext.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, openapi.Spec)
+}
+
+// Validate rejects requests that do not match the spec before they reach a
+// handler. echo runs it after routing, so rwContext.Path() is the route.
+func (Openapi OpenapiHandler) Validate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(rwContext echo.Context) error {
+		operation := Openapi.validator.Operation(rwContext.Request().Method, rwContext.Path())
+		if operation == nil {
+			return next(rwContext)
+		}
+
+		var body []byte
+		if rwContext.Request().Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(rwContext.Request().Body)
+			if err != nil {
+				return rwContext.JSON(http.StatusBadRequest, &models.Error{Message: err.Error()})
+			}
+			rwContext.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		pathParams := make(map[string]string)
+		for iter, name := range rwContext.ParamNames() {
+			pathParams[name] = rwContext.ParamValues()[iter]
+		}
+
+		fieldErrors := operation.Validate(pathParams, rwContext.QueryParams(), body)
+		if len(fieldErrors) != 0 {
+			return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "request does not match the API schema", Errors: fieldErrors})
+		}
+
+		return next(rwContext)
+	}
+}
+
+func (Openapi OpenapiHandler) SetupHandlers(server *echo.Echo) {
+	server.GET("/api/openapi.json", Openapi.GetSpec)
+	server.Use(Openapi.Validate)
+}
@@ -5,9 +5,12 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/jackc/pgx"
 	"github.com/labstack/echo"
+	forumErrors "vk_db_project/app/errors"
 	"vk_db_project/app/models"
 	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
 )
 
 type PostHandler struct {
@@ -24,32 +27,121 @@ func (PostHandler PostHandler) GetPost(rwContext echo.Context) error {
 
 	val := strings.Split(related.Get("related"), ",")
 
-	allPostData, err := PostHandler.PostLogic.GetPostData(id, val)
+	options := models.RelatedOptions{}
+	options.ChildrenLimit, _ = strconv.Atoi(related.Get("childrenLimit"))
+	options.ChildrenSince, _ = strconv.ParseInt(related.Get("childrenSince"), 10, 64)
+	options.ContextSize, _ = strconv.Atoi(related.Get("contextSize"))
+
+	allPostData, err := PostHandler.PostLogic.GetPostData(id, val, rwContext.QueryParam("format"), options)
+
+	if err == forumErrors.UnknownFormat {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
 
 	if err != nil {
 
 		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "can't find post by id: " + rwContext.Param("id")})
 	}
 
+	// With related objects the body depends on more rows than the post.
+	if related.Get("related") == "" && notModified(rwContext, versionETag(rwContext.QueryParam("format"), allPostData.Post.Version)) {
+		return rwContext.NoContent(http.StatusNotModified)
+	}
+
 	return rwContext.JSON(http.StatusOK, allPostData)
 }
 
+type postBatchRequest struct {
+	Ids []int64 `json:"ids"`
+}
+
+// GetPosts takes the same related and format query params as GetPost. Every
+// requested id is a key of the answer; missing posts get a message instead.
+func (PostHandler PostHandler) GetPosts(rwContext echo.Context) error {
+	request := new(postBatchRequest)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	flags := strings.Split(rwContext.QueryParam("related"), ",")
+
+	posts, err := PostHandler.PostLogic.GetPostsData(request.Ids, flags, rwContext.QueryParam("format"))
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == forumErrors.UnknownFormat {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, posts)
+}
+
 func (PostHandler PostHandler) UpdatePost(rwContext echo.Context) error {
 	id, _ := strconv.ParseInt(rwContext.Param("id"), 10, 64)
 
 	msg := new(models.Post)
 	rwContext.Bind(msg)
 
-	currentMsg, err := PostHandler.PostLogic.UpdatePost(id, msg.Message)
+	version, ok := expectedVersion(rwContext)
+	if !ok {
+		return rwContext.JSON(http.StatusPreconditionFailed, models.Error{Message: forumErrors.VersionMismatch.Error()})
+	}
+
+	currentMsg, err := PostHandler.PostLogic.UpdatePost(id, rwContext.QueryParam("actor"), msg.Message, version)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, models.Error{Message: err.Error()})
+	}
+
+	if err == forumErrors.VersionMismatch {
+		return rwContext.JSON(http.StatusPreconditionFailed, models.Error{Message: err.Error()})
+	}
+
 	if err != nil {
 
 		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "can't find post by id: " + rwContext.Param("id")})
 	}
 
+	rwContext.Response().Header().Set(headerETag, versionETag("", currentMsg.Version))
+
 	return rwContext.JSON(http.StatusOK, currentMsg)
 }
 
+// DeletePost also deletes the replies under the post.
+func (PostHandler PostHandler) DeletePost(rwContext echo.Context) error {
+	id, _ := strconv.ParseInt(rwContext.Param("id"), 10, 64)
+
+	err := PostHandler.PostLogic.DeletePost(id, rwContext.QueryParam("actor"))
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "can't find post by id: " + rwContext.Param("id")})
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, models.Error{Message: err.Error()})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, models.Error{Message: err.Error()})
+	}
+
+	return rwContext.NoContent(http.StatusNoContent)
+}
+
 func (PostHandler PostHandler) SetupHandlers(server *echo.Echo) {
 	server.GET("/api/post/:id/details", PostHandler.GetPost)
 	server.POST("/api/post/:id/details", PostHandler.UpdatePost)
+	server.DELETE("/api/post/:id/details", PostHandler.DeletePost)
+	server.POST("/api/post/batch", PostHandler.GetPosts)
 }
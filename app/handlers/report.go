@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx"
+	"github.com/labstack/echo"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
+)
+
+type ReportHandler struct {
+	reportLogic uscases.IReportUsecase
+}
+
+func NewReportHandler(rLogic uscases.ReportUsecaseImpl) ReportHandler {
+	return ReportHandler{reportLogic: rLogic}
+}
+
+// reportError answers for the errors the report routes share; notFound is
+// the message for pgx.ErrNoRows.
+func reportError(rwContext echo.Context, err error, notFound string) error {
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	switch err {
+	case pgx.ErrNoRows:
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: notFound})
+	case forumErrors.UserNotFound:
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: err.Error()})
+	case forumErrors.Forbidden, forumErrors.Banned:
+		return rwContext.JSON(http.StatusForbidden, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+}
+
+// reportCreated is 201 for a new report and 200 when the reporter updated
+// their open one.
+func reportCreated(rwContext echo.Context, report models.Report, created bool) error {
+	if created {
+		return rwContext.JSON(http.StatusCreated, report)
+	}
+
+	return rwContext.JSON(http.StatusOK, report)
+}
+
+func (Report ReportHandler) ReportPost(rwContext echo.Context) error {
+	id, _ := strconv.ParseInt(rwContext.Param("id"), 10, 64)
+
+	request := new(models.Report)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	report, created, err := Report.reportLogic.ReportPost(id, rwContext.QueryParam("actor"), *request)
+	if err != nil {
+		return reportError(rwContext, err, "Can't find post with id: "+rwContext.Param("id"))
+	}
+
+	return reportCreated(rwContext, report, created)
+}
+
+func (Report ReportHandler) ReportThread(rwContext echo.Context) error {
+	slugOrId := rwContext.Param("slug_or_id")
+
+	request := new(models.Report)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	report, created, err := Report.reportLogic.ReportThread(slugOrId, rwContext.QueryParam("actor"), *request)
+	if err != nil {
+		return reportError(rwContext, err, "Can't find thread by slug or id: "+slugOrId)
+	}
+
+	return reportCreated(rwContext, report, created)
+}
+
+func (Report ReportHandler) GetReports(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
+
+	groups, err := Report.reportLogic.GetReports(slug, rwContext.QueryParam("actor"), rwContext.QueryParam("status"), limit)
+	if err != nil {
+		return reportError(rwContext, err, "Can't find forum by slug: "+slug)
+	}
+
+	return rwContext.JSON(http.StatusOK, groups)
+}
+
+func (Report ReportHandler) Resolve(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	request := new(models.Resolution)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	action, err := Report.reportLogic.Resolve(slug, rwContext.QueryParam("actor"), *request)
+	if err != nil {
+		return reportError(rwContext, err, "Can't find open reports of the "+request.Type+" in forum: "+slug)
+	}
+
+	return rwContext.JSON(http.StatusOK, action)
+}
+
+func (Report ReportHandler) SetupHandlers(server *echo.Echo) {
+	server.POST("/api/post/:id/report", Report.ReportPost)
+	server.POST("/api/thread/:slug_or_id/report", Report.ReportThread)
+	server.GET("/api/forum/:slug/reports", Report.GetReports)
+	server.POST("/api/forum/:slug/reports/resolve", Report.Resolve)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx"
+	"github.com/labstack/echo"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
+)
+
+type RoleHandler struct {
+	roleLogic uscases.IRoleUsecase
+}
+
+func NewRoleHandler(rLogic uscases.RoleUsecaseImpl) RoleHandler {
+	return RoleHandler{roleLogic: rLogic}
+}
+
+// roleError answers for the errors the role and ban routes share; notFound
+// is the message for pgx.ErrNoRows.
+func roleError(rwContext echo.Context, err error, notFound string) error {
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	switch err {
+	case pgx.ErrNoRows:
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: notFound})
+	case forumErrors.UserNotFound:
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: err.Error()})
+	case forumErrors.Forbidden, forumErrors.Banned:
+		return rwContext.JSON(http.StatusForbidden, &models.Error{Message: err.Error()})
+	case forumErrors.OwnerRole:
+		return rwContext.JSON(http.StatusConflict, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+}
+
+func (Role RoleHandler) GetRoles(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	roles, err := Role.roleLogic.GetRoles(slug, rwContext.QueryParam("actor"))
+	if err != nil {
+		return roleError(rwContext, err, "Can't find forum by slug: "+slug)
+	}
+
+	return rwContext.JSON(http.StatusOK, roles)
+}
+
+func (Role RoleHandler) GrantRole(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	request := new(models.ForumRole)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	role, err := Role.roleLogic.GrantRole(slug, rwContext.QueryParam("actor"), *request)
+	if err != nil {
+		return roleError(rwContext, err, "Can't find forum by slug: "+slug)
+	}
+
+	return rwContext.JSON(http.StatusOK, role)
+}
+
+func (Role RoleHandler) RevokeRole(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+	nickname := rwContext.Param("nickname")
+
+	err := Role.roleLogic.RevokeRole(slug, rwContext.QueryParam("actor"), nickname)
+	if err != nil {
+		return roleError(rwContext, err, "Can't find role of "+nickname+" in forum: "+slug)
+	}
+
+	return rwContext.NoContent(http.StatusNoContent)
+}
+
+func (Role RoleHandler) GetBans(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	bans, err := Role.roleLogic.GetBans(slug, rwContext.QueryParam("actor"))
+	if err != nil {
+		return roleError(rwContext, err, "Can't find forum by slug: "+slug)
+	}
+
+	return rwContext.JSON(http.StatusOK, bans)
+}
+
+func (Role RoleHandler) Ban(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	request := new(models.ForumBan)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	ban, err := Role.roleLogic.Ban(slug, rwContext.QueryParam("actor"), *request)
+	if err != nil {
+		return roleError(rwContext, err, "Can't find forum by slug: "+slug)
+	}
+
+	return rwContext.JSON(http.StatusOK, ban)
+}
+
+func (Role RoleHandler) Unban(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+	nickname := rwContext.Param("nickname")
+
+	err := Role.roleLogic.Unban(slug, rwContext.QueryParam("actor"), nickname)
+	if err != nil {
+		return roleError(rwContext, err, "Can't find ban of "+nickname+" in forum: "+slug)
+	}
+
+	return rwContext.NoContent(http.StatusNoContent)
+}
+
+func (Role RoleHandler) SetupHandlers(server *echo.Echo) {
+	server.GET("/api/forum/:slug/roles", Role.GetRoles)
+	server.POST("/api/forum/:slug/roles", Role.GrantRole)
+	server.DELETE("/api/forum/:slug/roles/:nickname", Role.RevokeRole)
+	server.GET("/api/forum/:slug/bans", Role.GetBans)
+	server.POST("/api/forum/:slug/bans", Role.Ban)
+	server.DELETE("/api/forum/:slug/bans/:nickname", Role.Unban)
+}
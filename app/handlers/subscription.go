@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx"
+	"github.com/labstack/echo"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
+)
+
+type SubscriptionHandler struct {
+	subscriptionLogic uscases.ISubscriptionUsecase
+}
+
+func NewSubscriptionHandler(sLogic uscases.SubscriptionUsecaseImpl) SubscriptionHandler {
+	return SubscriptionHandler{subscriptionLogic: sLogic}
+}
+
+// subscriber reads the nickname from the body, or from ?nickname= for a
+// DELETE sent without one.
+func subscriber(rwContext echo.Context) (string, error) {
+	request := new(models.Subscription)
+	if rwContext.Request().ContentLength != 0 {
+		if err := rwContext.Bind(request); err != nil {
+			return "", err
+		}
+	}
+
+	if request.Nickname == "" {
+		return rwContext.QueryParam("nickname"), nil
+	}
+
+	return request.Nickname, nil
+}
+
+func (Subscription SubscriptionHandler) Subscribe(rwContext echo.Context) error {
+	slugOrId := rwContext.Param("slug_or_id")
+
+	nickname, err := subscriber(rwContext)
+	if err != nil {
+		return rwContext.JSON(http.StatusBadRequest, &models.Error{Message: err.Error()})
+	}
+
+	subscription, err := Subscription.subscriptionLogic.Subscribe(nickname, slugOrId)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "can't find user or thread by slug_or_id: " + slugOrId})
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, &models.Error{Message: err.Error()})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, subscription)
+}
+
+func (Subscription SubscriptionHandler) Unsubscribe(rwContext echo.Context) error {
+	slugOrId := rwContext.Param("slug_or_id")
+
+	nickname, err := subscriber(rwContext)
+	if err != nil {
+		return rwContext.JSON(http.StatusBadRequest, &models.Error{Message: err.Error()})
+	}
+
+	err = Subscription.subscriptionLogic.Unsubscribe(nickname, slugOrId)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "can't find user or thread by slug_or_id: " + slugOrId})
+	}
+
+	if err == forumErrors.NotSubscribed {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: err.Error()})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.NoContent(http.StatusNoContent)
+}
+
+func (Subscription SubscriptionHandler) MarkSeen(rwContext echo.Context) error {
+	slugOrId := rwContext.Param("slug_or_id")
+
+	request := new(models.Subscription)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, &models.Error{Message: err.Error()})
+	}
+
+	subscription, err := Subscription.subscriptionLogic.MarkSeen(request.Nickname, slugOrId, request.LastSeen)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "can't find user or thread by slug_or_id: " + slugOrId})
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, &models.Error{Message: err.Error()})
+	}
+
+	if err == forumErrors.NotSubscribed {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: err.Error()})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, subscription)
+}
+
+func (Subscription SubscriptionHandler) GetSubscriptions(rwContext echo.Context) error {
+	nickname := rwContext.Param("nickname")
+	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
+	since, _ := strconv.ParseInt(rwContext.QueryParam("since"), 10, 64)
+
+	subscriptions, err := Subscription.subscriptionLogic.GetSubscriptions(nickname, limit, since)
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, subscriptions)
+}
+
+func (Subscription SubscriptionHandler) GetSettings(rwContext echo.Context) error {
+	nickname := rwContext.Param("nickname")
+
+	settings, err := Subscription.subscriptionLogic.GetSettings(nickname)
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, settings)
+}
+
+func (Subscription SubscriptionHandler) UpdateSettings(rwContext echo.Context) error {
+	nickname := rwContext.Param("nickname")
+
+	settings := new(models.SubscriptionSettings)
+	if err := rwContext.Bind(settings); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, &models.Error{Message: err.Error()})
+	}
+
+	answer, err := Subscription.subscriptionLogic.UpdateSettings(nickname, *settings)
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusOK, answer)
+}
+
+func (Subscription SubscriptionHandler) SetupHandlers(server *echo.Echo) {
+	server.POST("/api/thread/:slug_or_id/subscribe", Subscription.Subscribe)
+	server.DELETE("/api/thread/:slug_or_id/subscribe", Subscription.Unsubscribe)
+	server.POST("/api/thread/:slug_or_id/seen", Subscription.MarkSeen)
+	server.GET("/api/user/:nickname/subscriptions", Subscription.GetSubscriptions)
+	server.GET("/api/user/:nickname/subscriptions/settings", Subscription.GetSettings)
+	server.POST("/api/user/:nickname/subscriptions/settings", Subscription.UpdateSettings)
+}
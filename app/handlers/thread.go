@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/jackc/pgx"
 	"github.com/labstack/echo"
+	forumErrors "vk_db_project/app/errors"
 	"vk_db_project/app/models"
 	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
 )
 
 type ThreadHandler struct {
@@ -26,10 +29,18 @@ func (Thread ThreadHandler) CreatePosts(rwContext echo.Context) error {
 	rwContext.Bind(&posts)
 
 	posts, err := Thread.threadLogic.CreatePosts(slugOrId, posts)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
 	if err == pgx.ErrNoRows {
 		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "can't find thread by slug_or_id: " + slugOrId})
 	}
 
+	if err == forumErrors.ThreadLocked || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, models.Error{Message: err.Error()})
+	}
+
 	if err != nil {
 		if err.Error() == "no user" {
 			return rwContext.JSON(http.StatusNotFound, models.Error{Message: "Can't find post author by nickname: "})
@@ -58,6 +69,9 @@ func (Thread ThreadHandler) VoteThread(rwContext echo.Context) error {
 	rwContext.Bind(&vote)
 
 	thread, err := Thread.threadLogic.VoteThread(slugOrId, vote.Nickname, vote.Voice)
+	if err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, models.Error{Message: err.Error()})
+	}
 
 	if err != nil {
 		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "can't vote by slug_or_id:" + slugOrId})
@@ -69,12 +83,20 @@ func (Thread ThreadHandler) VoteThread(rwContext echo.Context) error {
 func (Thread ThreadHandler) GetThread(rwContext echo.Context) error {
 	slugOrId := rwContext.Param("slug_or_id")
 
-	thread, err := Thread.threadLogic.GetThread(slugOrId)
+	thread, err := Thread.threadLogic.GetThread(slugOrId, rwContext.QueryParam("format"))
+
+	if err == forumErrors.UnknownFormat {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
 
 	if err != nil {
 		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "can't get thread by slug_or_id:" + slugOrId})
 	}
 
+	if notModified(rwContext, versionETag(rwContext.QueryParam("format"), thread.Version, int64(thread.Votes))) {
+		return rwContext.NoContent(http.StatusNotModified)
+	}
+
 	return rwContext.JSON(http.StatusOK, thread)
 }
 
@@ -93,11 +115,19 @@ func (Thread ThreadHandler) GetPosts(rwContext echo.Context) error {
 		sortType = "flat"
 	}
 
-	posts, err := Thread.threadLogic.GetPosts(slugOrId, limit, since, sortType, desc)
+	posts, err := Thread.threadLogic.GetPosts(slugOrId, limit, since, sortType, desc, rwContext.QueryParam("format"))
+	if err == forumErrors.UnknownFormat {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
 	if err != nil {
 		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "can't get thread by slug_or_id:" + slugOrId})
 	}
 
+	if notModified(rwContext, postsETag(rwContext.QueryParam("format"), posts)) {
+		return rwContext.NoContent(http.StatusNotModified)
+	}
+
 	return rwContext.JSON(http.StatusOK, posts)
 }
 
@@ -106,19 +136,126 @@ func (Thread ThreadHandler) UpdateThread(rwContext echo.Context) error {
 	newThread := new(models.Thread)
 	rwContext.Bind(newThread)
 
-	thread, err := Thread.threadLogic.UpdateThread(slugOrId, *newThread)
+	version, ok := expectedVersion(rwContext)
+	if !ok {
+		return rwContext.JSON(http.StatusPreconditionFailed, models.Error{Message: forumErrors.VersionMismatch.Error()})
+	}
+
+	thread, err := Thread.threadLogic.UpdateThread(slugOrId, rwContext.QueryParam("actor"), *newThread, version)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, models.Error{Message: err.Error()})
+	}
+
+	if err == forumErrors.VersionMismatch {
+		return rwContext.JSON(http.StatusPreconditionFailed, models.Error{Message: err.Error()})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "Can't find thread by slug_or_id: " + slugOrId})
+	}
+
+	rwContext.Response().Header().Set(headerETag, versionETag("", thread.Version, int64(thread.Votes)))
+
+	return rwContext.JSON(http.StatusOK, thread)
+}
+
+type lockRequest struct {
+	Locked bool `json:"locked"`
+}
+
+func (Thread ThreadHandler) LockThread(rwContext echo.Context) error {
+	slugOrId := rwContext.Param("slug_or_id")
+
+	request := new(lockRequest)
+	if err := rwContext.Bind(request); err != nil {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	thread, err := Thread.threadLogic.LockThread(slugOrId, rwContext.QueryParam("actor"), request.Locked)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "Can't find thread by slug_or_id: " + slugOrId})
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, models.Error{Message: err.Error()})
+	}
 
 	if err != nil {
-		return rwContext.JSON(http.StatusNotFound, models.Error{"Can't find thread by slug_or_id: " + slugOrId})
+		return rwContext.JSON(http.StatusInternalServerError, models.Error{Message: err.Error()})
 	}
 
+	rwContext.Response().Header().Set(headerETag, versionETag("", thread.Version, int64(thread.Votes)))
+
 	return rwContext.JSON(http.StatusOK, thread)
 }
 
+// DeleteThread deletes the thread with all of its posts.
+func (Thread ThreadHandler) DeleteThread(rwContext echo.Context) error {
+	slugOrId := rwContext.Param("slug_or_id")
+
+	err := Thread.threadLogic.DeleteThread(slugOrId, rwContext.QueryParam("actor"))
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "Can't find thread by slug_or_id: " + slugOrId})
+	}
+
+	if err == forumErrors.Forbidden || err == forumErrors.Banned {
+		return rwContext.JSON(http.StatusForbidden, models.Error{Message: err.Error()})
+	}
+
+	if err != nil {
+		return rwContext.JSON(http.StatusInternalServerError, models.Error{Message: err.Error()})
+	}
+
+	return rwContext.NoContent(http.StatusNoContent)
+}
+
+func (Thread ThreadHandler) ExportThread(rwContext echo.Context) error {
+	slugOrId := rwContext.Param("slug_or_id")
+	format := rwContext.QueryParam("format")
+
+	err := Thread.threadLogic.ExportThread(slugOrId, format, func(thread models.Thread) io.Writer {
+		contentType, extension := uscases.ExportContentType(format)
+		rwContext.Response().Header().Set(echo.HeaderContentType, contentType)
+		rwContext.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=\"thread-"+strconv.Itoa(thread.Id)+"."+extension+"\"")
+		rwContext.Response().WriteHeader(http.StatusOK)
+
+		return rwContext.Response()
+	})
+
+	if err == forumErrors.UnknownExportFormat {
+		return rwContext.JSON(http.StatusBadRequest, models.Error{Message: err.Error()})
+	}
+
+	if err == pgx.ErrNoRows {
+		return rwContext.JSON(http.StatusNotFound, models.Error{Message: "Can't find thread by slug_or_id: " + slugOrId})
+	}
+
+	if err != nil && !rwContext.Response().Committed {
+		return rwContext.JSON(http.StatusInternalServerError, models.Error{Message: err.Error()})
+	}
+
+	return err
+}
+
 func (Thread ThreadHandler) SetupHandlers(server *echo.Echo) {
 	server.POST("/api/thread/:slug_or_id/create", Thread.CreatePosts)
 	server.POST("/api/thread/:slug_or_id/vote", Thread.VoteThread)
 	server.POST("/api/thread/:slug_or_id/details", Thread.UpdateThread)
 	server.GET("/api/thread/:slug_or_id/details", Thread.GetThread)
+	server.DELETE("/api/thread/:slug_or_id/details", Thread.DeleteThread)
+	server.POST("/api/thread/:slug_or_id/lock", Thread.LockThread)
 	server.GET("/api/thread/:slug_or_id/posts", Thread.GetPosts)
+	server.GET("/api/thread/:slug_or_id/export", Thread.ExportThread)
 }
\ No newline at end of file
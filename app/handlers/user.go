@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/jackc/pgx"
 	"github.com/labstack/echo"
 	"vk_db_project/app/models"
 	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
 )
 
 type UserHandler struct {
@@ -32,6 +34,10 @@ func (User UserHandler) CreateUser(rwContext echo.Context) error {
 	rwContext.Bind(newUserData)
 	newUserData.Nickname = nickname
 	answer, err := User.userLogic.CreateUser(*newUserData)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
 	if err != nil {
 		return rwContext.JSON(http.StatusConflict, answer)
 	}
@@ -47,6 +53,25 @@ func (User UserHandler) GetUser(rwContext echo.Context) error {
 		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
 	}
 
+	etag := versionETag("", userData.Version)
+	for _, include := range strings.Split(rwContext.QueryParam("include"), ",") {
+		if include != "stats" {
+			continue
+		}
+
+		stats, err := User.userLogic.GetUserStats(userData.Nickname)
+		if err != nil {
+			return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
+		}
+
+		userData.Stats = &stats
+		etag = versionETag("", userData.Version, stats.Posts, stats.Threads, stats.Forums, stats.Votes)
+	}
+
+	if notModified(rwContext, etag) {
+		return rwContext.NoContent(http.StatusNotModified)
+	}
+
 	return rwContext.JSON(http.StatusOK, userData)
 }
 
@@ -57,6 +82,10 @@ func (User UserHandler) UpdateUser(rwContext echo.Context) error {
 	newUserData.Nickname = nickname
 
 	answer, err := User.userLogic.UpdateUserData(*newUserData)
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
 	if err == pgx.ErrNoRows {
 		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: "Can't find user by nickname: " + nickname})
 	}
@@ -65,6 +94,8 @@ func (User UserHandler) UpdateUser(rwContext echo.Context) error {
 		return rwContext.JSON(http.StatusConflict, &models.Error{Message: "This email is already registered by user: " + nickname})
 	}
 
+	rwContext.Response().Header().Set(headerETag, versionETag("", answer.Version))
+
 	return rwContext.JSON(http.StatusOK, answer)
 }
 
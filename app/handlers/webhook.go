@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx"
+	"github.com/labstack/echo"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+	"vk_db_project/app/uscases"
+	"vk_db_project/app/validation"
+)
+
+type WebhookHandler struct {
+	webhookLogic uscases.IWebhookUsecase
+}
+
+func NewWebhookHandler(wLogic uscases.WebhookUsecaseImpl) WebhookHandler {
+	return WebhookHandler{webhookLogic: wLogic}
+}
+
+// webhookError answers for the errors the webhook routes share; notFound is
+// the message for pgx.ErrNoRows.
+func webhookError(rwContext echo.Context, err error, notFound string) error {
+	if fieldErrors, ok := err.(validation.Errors); ok {
+		return rwContext.JSON(http.StatusBadRequest, &models.ValidationError{Message: "invalid input", Errors: fieldErrors})
+	}
+
+	switch err {
+	case pgx.ErrNoRows:
+		return rwContext.JSON(http.StatusNotFound, &models.Error{Message: notFound})
+	case forumErrors.InvalidUrl, forumErrors.InvalidEvent:
+		return rwContext.JSON(http.StatusBadRequest, &models.Error{Message: err.Error()})
+	case forumErrors.Forbidden, forumErrors.Banned:
+		return rwContext.JSON(http.StatusForbidden, &models.Error{Message: err.Error()})
+	}
+
+	return rwContext.JSON(http.StatusInternalServerError, &models.Error{Message: err.Error()})
+}
+
+func (Webhook WebhookHandler) CreateWebhook(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	webhookReq := new(models.Webhook)
+	rwContext.Bind(webhookReq)
+
+	webhook, err := Webhook.webhookLogic.CreateWebhook(slug, rwContext.QueryParam("actor"), *webhookReq)
+	if err != nil {
+		return webhookError(rwContext, err, "Can't find forum by slug: "+slug)
+	}
+
+	return rwContext.JSON(http.StatusCreated, webhook)
+}
+
+func (Webhook WebhookHandler) GetWebhooks(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+
+	webhooks, err := Webhook.webhookLogic.GetWebhooks(slug, rwContext.QueryParam("actor"))
+	if err != nil {
+		return webhookError(rwContext, err, "Can't find forum by slug: "+slug)
+	}
+
+	return rwContext.JSON(http.StatusOK, webhooks)
+}
+
+func (Webhook WebhookHandler) GetDeliveries(rwContext echo.Context) error {
+	slug := rwContext.Param("slug")
+	id, _ := strconv.ParseInt(rwContext.Param("id"), 10, 64)
+	limit, _ := strconv.Atoi(rwContext.QueryParam("limit"))
+	since, _ := strconv.ParseInt(rwContext.QueryParam("since"), 10, 64)
+
+	deliveries, err := Webhook.webhookLogic.GetDeliveries(slug, rwContext.QueryParam("actor"), id, limit, since)
+	if err != nil {
+		return webhookError(rwContext, err, "Can't find webhook by id: "+rwContext.Param("id"))
+	}
+
+	return rwContext.JSON(http.StatusOK, deliveries)
+}
+
+func (Webhook WebhookHandler) SetupHandlers(server *echo.Echo) {
+	server.POST("/api/forum/:slug/webhooks", Webhook.CreateWebhook)
+	server.GET("/api/forum/:slug/webhooks", Webhook.GetWebhooks)
+	server.GET("/api/forum/:slug/webhooks/:id/deliveries", Webhook.GetDeliveries)
+}
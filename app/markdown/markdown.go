@@ -0,0 +1,111 @@
+package markdown
+
+import (
+	"bytes"
+	"container/list"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const defaultCacheSize = 10000
+
+type Renderer struct {
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+
+	mutex   sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	html string
+}
+
+var defaultRenderer = NewRenderer(defaultCacheSize)
+
+// NewRenderer builds a CommonMark renderer that autolinks bare URLs and keeps
+// up to size rendered bodies in an LRU cache.
+func NewRenderer(size int) *Renderer {
+	return &Renderer{
+		markdown: goldmark.New(goldmark.WithExtensions(extension.Linkify)),
+		policy:   newPolicy(),
+		size:     size,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Render returns sanitized HTML for source. Callers pass the row kind, id and
+// edit version, so an edit changes the key and stale output is never served.
+func (r *Renderer) Render(kind string, id int64, version int64, source string) string {
+	key := kind + ":" + strconv.FormatInt(id, 10) + ":" + strconv.FormatInt(version, 10)
+
+	r.mutex.Lock()
+	if element, ok := r.entries[key]; ok {
+		r.order.MoveToFront(element)
+		html := element.Value.(*cacheEntry).html
+		r.mutex.Unlock()
+		return html
+	}
+	r.mutex.Unlock()
+
+	html := r.renderUncached(source)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.entries[key]; !ok {
+		r.entries[key] = r.order.PushFront(&cacheEntry{key: key, html: html})
+		if r.order.Len() > r.size {
+			oldest := r.order.Back()
+			r.order.Remove(oldest)
+			delete(r.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+
+	return html
+}
+
+func (r *Renderer) renderUncached(source string) string {
+	var buffer bytes.Buffer
+	if err := r.markdown.Convert([]byte(source), &buffer); err != nil {
+		return r.policy.Sanitize(source)
+	}
+
+	return r.policy.Sanitize(buffer.String())
+}
+
+// Render uses the process-wide renderer and cache.
+func Render(kind string, id int64, version int64, source string) string {
+	return defaultRenderer.Render(kind, id, version, source)
+}
+
+// newPolicy is the allow-list of everything CommonMark can produce. Raw HTML
+// in the source is already dropped by goldmark, this also catches unsafe
+// URLs and attributes.
+func newPolicy() *bluemonday.Policy {
+	policy := bluemonday.NewPolicy()
+
+	policy.AllowElements("p", "br", "hr", "em", "strong", "del", "blockquote", "pre", "code",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6")
+	policy.AllowAttrs("start").Matching(regexp.MustCompile(`^[0-9]+$`)).OnElements("ol")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+
+	policy.AllowAttrs("href", "title").OnElements("a")
+	policy.AllowAttrs("src", "alt", "title").OnElements("img")
+	policy.AllowURLSchemes("http", "https", "mailto")
+	policy.AllowRelativeURLs(true)
+	policy.RequireParseableURLs(true)
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return policy
+}
@@ -0,0 +1,186 @@
+package mbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Message is one mail of the box. Date is zero when neither the Date header
+// nor the "From " line has a date that parses.
+type Message struct {
+	Id         string
+	InReplyTo  string
+	References []string
+	FromName   string
+	FromEmail  string
+	Subject    string
+	Date       time.Time
+	Body       string
+}
+
+var messageIdPattern = regexp.MustCompile(`<[^<>\s]+>`)
+
+// Reader splits an mbox file on its "From " separator lines. Both mboxo and
+// mboxrd quoting of body lines is undone.
+type Reader struct {
+	scanner *bufio.Scanner
+	started bool
+	next    string
+}
+
+func NewReader(in io.Reader) *Reader {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	return &Reader{scanner: scanner}
+}
+
+// Next returns the next message or io.EOF after the last one.
+func (reader *Reader) Next() (*Message, error) {
+	var raw bytes.Buffer
+	envelope := reader.next
+
+	for reader.scanner.Scan() {
+		line := reader.scanner.Text()
+
+		if strings.HasPrefix(line, "From ") {
+			if reader.started && raw.Len() != 0 {
+				reader.next = line
+				return parse(raw.Bytes(), envelope)
+			}
+
+			reader.started = true
+			envelope = line
+			continue
+		}
+
+		if !reader.started {
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			line = line[1:]
+		}
+
+		raw.WriteString(line)
+		raw.WriteString("\r\n")
+	}
+
+	if err := reader.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if raw.Len() == 0 {
+		return nil, io.EOF
+	}
+
+	reader.started = false
+	return parse(raw.Bytes(), envelope)
+}
+
+func parse(raw []byte, envelope string) (*Message, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := new(mime.WordDecoder)
+	message := &Message{
+		Id:         firstMessageId(parsed.Header.Get("Message-Id")),
+		InReplyTo:  firstMessageId(parsed.Header.Get("In-Reply-To")),
+		References: messageIdPattern.FindAllString(parsed.Header.Get("References"), -1),
+	}
+
+	message.Subject, err = decoder.DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		message.Subject = parsed.Header.Get("Subject")
+	}
+	message.Subject = strings.TrimSpace(message.Subject)
+
+	if from, err := mail.ParseAddress(parsed.Header.Get("From")); err == nil {
+		message.FromName = from.Name
+		message.FromEmail = strings.ToLower(from.Address)
+	}
+
+	message.Date, err = parsed.Header.Date()
+	if err != nil {
+		message.Date = envelopeDate(envelope)
+	}
+
+	body, err := textBody(parsed.Header.Get("Content-Type"), parsed.Header.Get("Content-Transfer-Encoding"), parsed.Body)
+	if err != nil {
+		return nil, err
+	}
+	message.Body = strings.TrimSpace(strings.Replace(body, "\r\n", "\n", -1))
+
+	return message, nil
+}
+
+// textBody returns the first text/plain part of the body with its transfer
+// encoding removed.
+func textBody(contentType string, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextPart()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+
+			text, err := textBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil || text != "" {
+				return text, err
+			}
+		}
+	}
+
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	text, err := ioutil.ReadAll(body)
+	return string(text), err
+}
+
+func firstMessageId(header string) string {
+	return messageIdPattern.FindString(header)
+}
+
+// envelopeDate parses the asctime date at the end of a "From sender date"
+// line, or returns the zero time.
+func envelopeDate(envelope string) time.Time {
+	fields := strings.Fields(envelope)
+	if len(fields) >= 7 {
+		date, err := time.Parse(time.ANSIC, strings.Join(fields[len(fields)-5:], " "))
+		if err == nil {
+			return date
+		}
+	}
+
+	return time.Time{}
+}
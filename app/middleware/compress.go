@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo"
+)
+
+// brotliLevel trades ratio for speed; responses are compressed on the fly.
+const brotliLevel = 4
+
+// compressWriter holds the body back until it reaches the threshold, so small
+// answers, errors and 304s go out as they are.
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding  string
+	threshold int
+	status    int
+	buffer    bytes.Buffer
+	encoder   io.WriteCloser
+}
+
+func (Writer *compressWriter) WriteHeader(code int) {
+	Writer.status = code
+}
+
+func (Writer *compressWriter) Write(data []byte) (int, error) {
+	if Writer.encoder != nil {
+		return Writer.encoder.Write(data)
+	}
+
+	Writer.buffer.Write(data)
+	if Writer.buffer.Len() < Writer.threshold {
+		return len(data), nil
+	}
+
+	if err := Writer.start(); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// Flush is used by streaming handlers such as the thread export: whatever
+// was buffered so far is sent, compressed since the body is still growing.
+func (Writer *compressWriter) Flush() {
+	if Writer.encoder == nil {
+		if Writer.buffer.Len() == 0 {
+			return
+		}
+		Writer.start()
+	}
+
+	if flusher, ok := Writer.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+
+	if flusher, ok := Writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (Writer *compressWriter) start() error {
+	header := Writer.Header()
+
+	if header.Get(echo.HeaderContentEncoding) != "" {
+		Writer.encoder = nopCloser{Writer.ResponseWriter}
+	} else {
+		header.Set(echo.HeaderContentEncoding, Writer.encoding)
+		header.Del(echo.HeaderContentLength)
+
+		switch Writer.encoding {
+		case "br":
+			Writer.encoder = brotli.NewWriterLevel(Writer.ResponseWriter, brotliLevel)
+		default:
+			Writer.encoder = gzip.NewWriter(Writer.ResponseWriter)
+		}
+	}
+
+	Writer.writeHeader()
+
+	_, err := Writer.encoder.Write(Writer.buffer.Bytes())
+	Writer.buffer.Reset()
+
+	return err
+}
+
+func (Writer *compressWriter) writeHeader() {
+	if Writer.status == 0 {
+		Writer.status = http.StatusOK
+	}
+
+	Writer.ResponseWriter.WriteHeader(Writer.status)
+}
+
+func (Writer *compressWriter) Close() error {
+	if Writer.encoder != nil {
+		return Writer.encoder.Close()
+	}
+
+	if Writer.status == 0 && Writer.buffer.Len() == 0 {
+		return nil
+	}
+
+	Writer.writeHeader()
+	_, err := Writer.ResponseWriter.Write(Writer.buffer.Bytes())
+
+	return err
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// Compress gzip or brotli encodes responses of at least threshold bytes for
+// clients that accept it, preferring brotli on a tie.
+func Compress(threshold int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(rwContext echo.Context) error {
+			response := rwContext.Response()
+			response.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+
+			header := rwContext.Request().Header.Get(echo.HeaderAcceptEncoding)
+			encoding := ""
+			if header != "" {
+				encoding = negotiate(header, "br", "gzip")
+			}
+
+			if encoding == "" || rwContext.Request().Method == http.MethodHead {
+				return next(rwContext)
+			}
+
+			writer := &compressWriter{ResponseWriter: response.Writer, encoding: encoding, threshold: threshold}
+			response.Writer = writer
+			defer func() {
+				writer.Close()
+				response.Writer = writer.ResponseWriter
+			}()
+
+			return next(rwContext)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Package middleware holds the echo middlewares that shape every response:
+// content negotiation between JSON and MessagePack, and compression.
+package middleware
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const MIMEApplicationMsgpack = "application/msgpack"
+
+// negotiatedContext answers rwContext.JSON calls with MessagePack when the
+// client asked for it, so handlers keep a single way to write a response.
+type negotiatedContext struct {
+	echo.Context
+}
+
+func (Context negotiatedContext) JSON(code int, value interface{}) error {
+	Context.Response().Header().Add(echo.HeaderVary, "Accept")
+
+	offer := negotiate(Context.Request().Header.Get(echo.HeaderAccept), echo.MIMEApplicationJSON, MIMEApplicationMsgpack, "application/x-msgpack")
+	if offer == echo.MIMEApplicationJSON || offer == "" {
+		return Context.Context.JSON(code, value)
+	}
+
+	encoded, err := marshalMsgpack(value)
+	if err != nil {
+		return err
+	}
+
+	return Context.Blob(code, offer, encoded)
+}
+
+// marshalMsgpack reuses the json struct tags, so field names and omitempty
+// are the same in both formats.
+func marshalMsgpack(value interface{}) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+
+	encoder := msgpack.NewEncoder(buffer)
+	encoder.SetCustomStructTag("json")
+	encoder.UseCompactInts(true)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func Negotiate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(rwContext echo.Context) error {
+		return next(negotiatedContext{Context: rwContext})
+	}
+}
+
+// negotiate returns the offer the client ranks highest in an Accept-style
+// header, the earlier offer on a tie, or "" if none is acceptable. An empty
+// header accepts the first offer.
+func negotiate(header string, offers ...string) string {
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		if quality := acceptQuality(header, offer); quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+
+	return best
+}
+
+// acceptQuality is the q value of the most specific range matching offer.
+func acceptQuality(header, offer string) float64 {
+	quality, specificity := 0.0, -1
+
+	for _, item := range strings.Split(header, ",") {
+		params := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+
+		matched := -1
+		switch {
+		case name == offer:
+			matched = 2
+		case strings.HasSuffix(name, "/*") && strings.HasPrefix(offer, name[:len(name)-1]):
+			matched = 1
+		case name == "*/*" || name == "*":
+			matched = 0
+		}
+
+		if matched <= specificity {
+			continue
+		}
+
+		value := 1.0
+		for _, param := range params[1:] {
+			pair := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(pair) == 2 && pair[0] == "q" {
+				if parsed, err := strconv.ParseFloat(pair[1], 64); err == nil {
+					value = parsed
+				}
+			}
+		}
+
+		quality, specificity = value, matched
+	}
+
+	return quality
+}
@@ -5,4 +5,29 @@ type FullPost struct {
 	Post   *Post   `json:"post,omitempty"`
 	Thread *Thread    `json:"thread,omitempty"`
 	Forum  *Forum     `json:"forum,omitempty"`
+
+	Parent    *Post        `json:"parent,omitempty"`
+	Ancestors []Post       `json:"ancestors,omitempty"`
+	Children  []Post       `json:"children,omitempty"`
+	Context   *PostContext `json:"context,omitempty"`
+}
+
+// PostContext holds the siblings of a post, in tree order, around it.
+type PostContext struct {
+	Before []Post `json:"before"`
+	After  []Post `json:"after"`
+}
+
+// RelatedOptions pages the post lists of a FullPost.
+type RelatedOptions struct {
+	ChildrenLimit int
+	ChildrenSince int64
+	ContextSize   int
+}
+
+// PostLookup is one entry of a batch post lookup: the post with the related
+// objects that were asked for, or the reason it was not found.
+type PostLookup struct {
+	FullPost
+	Message string `json:"message,omitempty"`
 }
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// BucketSizes are the lengths of the buckets. Weeks start on Monday, both
+// in date_trunc and when truncating time.Time, whose zero is a Monday.
+var BucketSizes = map[string]time.Duration{
+	BucketHour: time.Hour,
+	BucketDay:  24 * time.Hour,
+	BucketWeek: 7 * 24 * time.Hour,
+}
+
+// ActivityBucket counts what happened from Start to the next bucket. Users
+// are the ones that posted in the forum, or any forum, for the first time.
+type ActivityBucket struct {
+	Start   time.Time `json:"start"`
+	Threads int64     `json:"threads"`
+	Posts   int64     `json:"posts"`
+	Users   int64     `json:"users"`
+	Votes   int64     `json:"votes"`
+}
+
+// ActivityStats covers [From, To) in UTC buckets; the first bucket starts
+// at or before From and only counts from From on.
+type ActivityStats struct {
+	Forum   string           `json:"forum,omitempty"`
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Bucket  string           `json:"bucket"`
+	Buckets []ActivityBucket `json:"buckets"`
+}
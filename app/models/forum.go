@@ -1,10 +1,14 @@
 package models
 
+// TotalPosts and TotalThreads include the sub-forums at any depth.
 type Forum struct {
-	Posts   int64  `json:"posts,omitempty"`
-	Threads int    `json:"threads,omitempty"`
-	Slug    string `json:"slug,omitempty"`
-	Title   string `json:"title,omitempty"`
-	User    string `json:"user,omitempty"`
+	Posts        int64  `json:"posts,omitempty"`
+	Threads      int    `json:"threads,omitempty"`
+	Slug         string `json:"slug,omitempty"`
+	Title        string `json:"title,omitempty"`
+	User         string `json:"user,omitempty"`
+	Parent       string `json:"parent,omitempty"`
+	TotalPosts   int64  `json:"totalPosts,omitempty"`
+	TotalThreads int64  `json:"totalThreads,omitempty"`
+	Version      int64  `json:"-"`
 }
-
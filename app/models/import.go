@@ -0,0 +1,10 @@
+package models
+
+type ImportResult struct {
+	Users   int `json:"users"`
+	Threads int `json:"threads"`
+	Posts   int `json:"posts"`
+	Skipped int `json:"skipped"`
+	// Rejected counts mails without a usable date.
+	Rejected int `json:"rejected"`
+}
@@ -0,0 +1,18 @@
+package models
+
+const (
+	MetricPosts   = "posts"
+	MetricThreads = "threads"
+	MetricVotes   = "votes"
+
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodAll   = "all"
+)
+
+type LeaderboardEntry struct {
+	Rank     int64  `json:"rank"`
+	Nickname string `json:"nickname"`
+	Value    int64  `json:"value"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	NotificationReply      = "reply"
+	NotificationThreadPost = "thread_post"
+	NotificationMention    = "mention"
+	// NotificationSubscription is a new post in a thread the user follows.
+	NotificationSubscription = "subscription"
+)
+
+type Notification struct {
+	Id      int64     `json:"id,omitempty"`
+	Kind    string    `json:"kind,omitempty"`
+	Author  string    `json:"author,omitempty"`
+	Post    int64     `json:"post,omitempty"`
+	Thread  int       `json:"thread,omitempty"`
+	Forum   string    `json:"forum,omitempty"`
+	Read    bool      `json:"read"`
+	Created time.Time `json:"created,omitempty"`
+}
+
+type NotificationsRead struct {
+	UpTo   int64 `json:"upTo,omitempty"`
+	Marked int64 `json:"marked"`
+	Unread int64 `json:"unread"`
+}
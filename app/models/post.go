@@ -15,5 +15,10 @@ type Post struct {
 	Message  string           `json:"message,omitempty"`
 	Parent   int64            `json:"parent,omitempty"`
 	Thread   int              `json:"thread,omitempty"`
+	Mentions []string         `json:"mentions,omitempty"`
 	Path     pgtype.Int8Array `json:"-"`
+	Version  int64            `json:"-"`
+	// ImportedId is the Message-ID of an imported mail, recorded in the
+	// transaction that creates the post.
+	ImportedId string `json:"-"`
 }
@@ -0,0 +1,9 @@
+package models
+
+// Thread orders of /api/forum/:slug/threads. SortNew is the plain date order.
+const (
+	SortNew    = "new"
+	SortHot    = "hot"
+	SortTop    = "top"
+	SortActive = "active"
+)
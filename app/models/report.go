@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+const (
+	ReportPost   = "post"
+	ReportThread = "thread"
+
+	ReportOpen     = "open"
+	ReportResolved = "resolved"
+
+	CategorySpam     = "spam"
+	CategoryAbuse    = "abuse"
+	CategoryOfftopic = "offtopic"
+	CategoryIllegal  = "illegal"
+	CategoryOther    = "other"
+
+	ResolveDismiss = "dismiss"
+	ResolveDelete  = "delete"
+	ResolveBan     = "ban"
+)
+
+type Report struct {
+	Id       int64     `json:"id,omitempty"`
+	Forum    string    `json:"forum,omitempty"`
+	Type     string    `json:"type,omitempty"`
+	Target   int64     `json:"target,omitempty"`
+	Thread   int64     `json:"thread,omitempty"`
+	Author   string    `json:"author,omitempty"`
+	Reporter string    `json:"reporter,omitempty"`
+	Category string    `json:"category"`
+	Text     string    `json:"text,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Created  time.Time `json:"created,omitempty"`
+}
+
+// Resolution is what a moderator does about the open reports of a target.
+type Resolution struct {
+	Type   string `json:"type"`
+	Target int64  `json:"target"`
+	Action string `json:"action"`
+	Note   string `json:"note,omitempty"`
+}
+
+type ReportAction struct {
+	Id        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Target    int64     `json:"target"`
+	Action    string    `json:"action"`
+	Note      string    `json:"note,omitempty"`
+	Moderator string    `json:"moderator,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Reports   int       `json:"reports"`
+	Created   time.Time `json:"created"`
+}
+
+// ReportGroup is one entry of the moderation queue: the reports of a target,
+// and for resolved ones the action that closed them.
+type ReportGroup struct {
+	Type         string         `json:"type"`
+	Target       int64          `json:"target"`
+	Thread       int64          `json:"thread"`
+	Author       string         `json:"author,omitempty"`
+	Count        int            `json:"count"`
+	Categories   map[string]int `json:"categories"`
+	LastReported time.Time      `json:"lastReported"`
+	Reports      []Report       `json:"reports"`
+	Action       *ReportAction  `json:"action,omitempty"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+const (
+	RoleOwner     = "owner"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+)
+
+// Actions checked by the permission service. ActionPost covers creating
+// threads and posts and voting, ActionReport reporting them, ActionSubscribe
+// following threads, ActionView reading the roles of the forum, ActionEdit
+// and ActionDelete a post or thread of someone, ActionModerate locking
+// threads, banning users, the ban list, webhooks and the report queue, and
+// ActionManage granting roles and moving the forum.
+const (
+	ActionPost      = "post"
+	ActionReport    = "report"
+	ActionSubscribe = "subscribe"
+	ActionView      = "view"
+	ActionEdit      = "edit"
+	ActionDelete    = "delete"
+	ActionModerate  = "moderate"
+	ActionManage    = "manage"
+)
+
+type ForumRole struct {
+	Nickname  string    `json:"nickname"`
+	Role      string    `json:"role"`
+	GrantedBy string    `json:"grantedBy,omitempty"`
+	Granted   time.Time `json:"granted"`
+}
+
+type ForumBan struct {
+	Nickname  string    `json:"nickname"`
+	Moderator string    `json:"moderator,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+// Access is what the permission service knows about a user in a forum; an
+// empty Role means no role.
+type Access struct {
+	Role   string
+	Banned bool
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Subscription is a thread a user follows. LastSeen is the newest post of
+// the thread at the last visit and NewPosts counts the posts after it.
+type Subscription struct {
+	Id       int64     `json:"id,omitempty"`
+	Nickname string    `json:"nickname,omitempty"`
+	Thread   *Thread   `json:"thread,omitempty"`
+	LastSeen int64     `json:"lastSeen"`
+	NewPosts int64     `json:"newPosts"`
+	Created  time.Time `json:"created,omitempty"`
+}
+
+type SubscriptionSettings struct {
+	AutoSubscribe bool `json:"autoSubscribe"`
+}
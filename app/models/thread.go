@@ -11,4 +11,29 @@ type Thread struct {
 	Slug    string    `json:"slug,omitempty"`
 	Title   string    `json:"title,omitempty"`
 	Votes   int       `json:"votes,omitempty"`
+	Locked  bool      `json:"locked,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	Version int64     `json:"-"`
+	// ImportedId is the Message-ID of an imported mail, recorded in the
+	// transaction that creates the thread.
+	ImportedId string `json:"-"`
+}
+
+const (
+	TagModeAll = "all"
+	TagModeAny = "any"
+)
+
+// ThreadFilter narrows a thread list down to threads with all, or with any
+// for TagModeAny, of Tags. An empty filter lets every thread through.
+// Recursive widens a forum's list to its sub-forums.
+type ThreadFilter struct {
+	Tags      []string
+	Mode      string
+	Recursive bool
+}
+
+type TagCount struct {
+	Tag     string `json:"tag"`
+	Threads int64  `json:"threads"`
 }
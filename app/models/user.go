@@ -1,8 +1,24 @@
 package models
 
+import "time"
+
 type UserModel struct {
-	Nickname string `json:"nickname,omitempty"`
-	Fullname string `json:"fullname,omitempty"`
-	Email    string `json:"email,omitempty"`
-	About    string `json:"about,omitempty"`
+	Nickname string     `json:"nickname,omitempty"`
+	Fullname string     `json:"fullname,omitempty"`
+	Email    string     `json:"email,omitempty"`
+	About    string     `json:"about,omitempty"`
+	Stats    *UserStats `json:"stats,omitempty"`
+	Version  int64      `json:"-"`
+}
+
+// UserStats is kept up to date by triggers in db.sql; Karma is derived from
+// the other counters when the stats are read.
+type UserStats struct {
+	Posts         int64      `json:"posts"`
+	Threads       int64      `json:"threads"`
+	Forums        int64      `json:"forums"`
+	Votes         int64      `json:"votes"`
+	Karma         int64      `json:"karma"`
+	FirstActivity *time.Time `json:"firstActivity,omitempty"`
+	LastActivity  *time.Time `json:"lastActivity,omitempty"`
 }
@@ -0,0 +1,11 @@
+package models
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Message string       `json:"message,omitempty"`
+	Errors  []FieldError `json:"errors"`
+}
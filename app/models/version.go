@@ -0,0 +1,4 @@
+package models
+
+// AnyVersion skips the optimistic locking check of an update.
+const AnyVersion int64 = -1
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+type Webhook struct {
+	Id      int64     `json:"id,omitempty"`
+	Forum   string    `json:"forum,omitempty"`
+	Url     string    `json:"url,omitempty"`
+	Secret  string    `json:"secret,omitempty"`
+	Events  []string  `json:"events,omitempty"`
+	Created time.Time `json:"created,omitempty"`
+}
+
+type WebhookEvent struct {
+	Id       int64     `json:"id,omitempty"`
+	Webhook  int64     `json:"webhook,omitempty"`
+	Url      string    `json:"-"`
+	Secret   string    `json:"-"`
+	Event    string    `json:"event,omitempty"`
+	Payload  []byte    `json:"-"`
+	Attempts int       `json:"attempts,omitempty"`
+	Created  time.Time `json:"created,omitempty"`
+}
+
+type WebhookDelivery struct {
+	Id         int64     `json:"id,omitempty"`
+	Webhook    int64     `json:"webhook,omitempty"`
+	Event      string    `json:"event,omitempty"`
+	EventId    int64     `json:"eventId,omitempty"`
+	Attempt    int       `json:"attempt,omitempty"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Duration   int64     `json:"duration,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	Created    time.Time `json:"created,omitempty"`
+}
+
+const (
+	EventThreadCreated = "thread.created"
+	EventPostCreated   = "post.created"
+	EventPostUpdated   = "post.updated"
+	EventThreadVoted   = "thread.voted"
+)
+
+var WebhookEvents = []string{EventThreadCreated, EventPostCreated, EventPostUpdated, EventThreadVoted}
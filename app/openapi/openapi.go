@@ -0,0 +1,161 @@
+// Package openapi embeds the API description of the server and checks
+// incoming requests against it. Only the part of JSON Schema used by
+// openapi.json is supported: type, format, enum, required, properties, items,
+// minimum/maximum, minLength/maxLength, minItems/maxItems, pattern and $ref.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+type parameter struct {
+	Name     string                 `json:"name"`
+	In       string                 `json:"in"`
+	Required bool                   `json:"required"`
+	Explode  *bool                  `json:"explode"`
+	Schema   map[string]interface{} `json:"schema"`
+	Ref      string                 `json:"$ref"`
+}
+
+type requestBody struct {
+	Required bool `json:"required"`
+	Content  map[string]struct {
+		Schema map[string]interface{} `json:"schema"`
+	} `json:"content"`
+}
+
+type operationSpec struct {
+	Parameters  []parameter  `json:"parameters"`
+	RequestBody *requestBody `json:"requestBody"`
+}
+
+type document struct {
+	Servers []struct {
+		Url string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]operationSpec `json:"paths"`
+	Components struct {
+		Parameters map[string]parameter              `json:"parameters"`
+		Schemas    map[string]map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+// Operation is one method of one path, ready to validate requests.
+type Operation struct {
+	validator    *Validator
+	parameters   []parameter
+	body         map[string]interface{}
+	bodyRequired bool
+}
+
+type Validator struct {
+	schemas    map[string]map[string]interface{}
+	patterns   map[string]*regexp.Regexp
+	operations map[string]*Operation
+}
+
+// NewValidator parses the embedded spec. Operations are keyed by the echo
+// route they are served on, e.g. "GET /api/forum/:slug/details".
+func NewValidator() (*Validator, error) {
+	doc := new(document)
+	if err := json.Unmarshal(Spec, doc); err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if len(doc.Servers) != 0 {
+		prefix = strings.TrimRight(doc.Servers[0].Url, "/")
+	}
+
+	validator := &Validator{schemas: doc.Components.Schemas, patterns: make(map[string]*regexp.Regexp), operations: make(map[string]*Operation)}
+
+	var raw interface{}
+	if err := json.Unmarshal(Spec, &raw); err != nil {
+		return nil, err
+	}
+	if err := validator.compilePatterns(raw); err != nil {
+		return nil, err
+	}
+
+	for path, methods := range doc.Paths {
+		route := prefix + pathToRoute(path)
+
+		for method, spec := range methods {
+			operation := &Operation{validator: validator}
+
+			for _, param := range spec.Parameters {
+				if param.Ref != "" {
+					shared, ok := doc.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+					if !ok {
+						return nil, errors.New("openapi: unknown parameter " + param.Ref)
+					}
+					param = shared
+				}
+				operation.parameters = append(operation.parameters, param)
+			}
+
+			if spec.RequestBody != nil {
+				operation.bodyRequired = spec.RequestBody.Required
+				if content, ok := spec.RequestBody.Content["application/json"]; ok {
+					operation.body = content.Schema
+				}
+			}
+
+			validator.operations[strings.ToUpper(method)+" "+route] = operation
+		}
+	}
+
+	return validator, nil
+}
+
+// Operation returns nil for routes the spec does not describe.
+func (Validator *Validator) Operation(method, route string) *Operation {
+	return Validator.operations[method+" "+route]
+}
+
+func pathToRoute(path string) string {
+	parts := strings.Split(path, "/")
+	for iter, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[iter] = ":" + part[1:len(part)-1]
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// compilePatterns walks the whole document once so requests never compile
+// or share a mutable cache.
+func (Validator *Validator) compilePatterns(node interface{}) error {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		for key, value := range typed {
+			if pattern, ok := value.(string); ok && key == "pattern" {
+				compiled, err := regexp.Compile(pattern)
+				if err != nil {
+					return err
+				}
+				Validator.patterns[pattern] = compiled
+				continue
+			}
+			if err := Validator.compilePatterns(value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, value := range typed {
+			if err := Validator.compilePatterns(value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vk_db_project/app/models"
+)
+
+// Validate checks path params, query params and the JSON body of a request
+// and returns every mismatch it finds, not only the first one.
+func (Op *Operation) Validate(pathParams map[string]string, query url.Values, body []byte) []models.FieldError {
+	fieldErrors := make([]models.FieldError, 0)
+
+	for _, param := range Op.parameters {
+		raw, present := "", false
+		var repeated []string
+
+		switch param.In {
+		case "path":
+			raw, present = pathParams[param.Name]
+		case "query":
+			raw, present = query.Get(param.Name), query.Get(param.Name) != ""
+			repeated = query[param.Name]
+		default:
+			continue
+		}
+
+		field := param.In + "." + param.Name
+		if !present {
+			if param.Required {
+				fieldErrors = append(fieldErrors, models.FieldError{Field: field, Message: "is required"})
+			}
+			continue
+		}
+
+		value, ok := Op.parseParameter(param, raw, repeated)
+		if !ok {
+			fieldErrors = append(fieldErrors, models.FieldError{Field: field, Message: "must be " + describeType(Op.validator.resolve(param.Schema))})
+			continue
+		}
+
+		Op.validator.check(param.Schema, value, field, &fieldErrors)
+	}
+
+	if Op.body == nil {
+		return fieldErrors
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if Op.bodyRequired {
+			fieldErrors = append(fieldErrors, models.FieldError{Field: "body", Message: "is required"})
+		}
+		return fieldErrors
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "body", Message: "is not valid JSON: " + err.Error()})
+		return fieldErrors
+	}
+
+	Op.validator.check(Op.body, value, "body", &fieldErrors)
+
+	// Object properties are walked in map order; keep the answer stable.
+	sort.SliceStable(fieldErrors, func(i, j int) bool {
+		return fieldErrors[i].Field < fieldErrors[j].Field
+	})
+
+	return fieldErrors
+}
+
+// parseParameter turns a raw path or query value into what encoding/json
+// would have produced for it, so both go through the same schema checks.
+// repeated holds every value of a query param, for exploded arrays.
+func (Op *Operation) parseParameter(param parameter, raw string, repeated []string) (interface{}, bool) {
+	schema := Op.validator.resolve(param.Schema)
+
+	switch schema["type"] {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, false
+		}
+		return json.Number(raw), true
+	case "boolean":
+		value, err := strconv.ParseBool(raw)
+		return value, err == nil
+	case "array":
+		// style=form, the only array style in the spec: a=x&a=y by default,
+		// a=x,y with explode=false.
+		values := repeated
+		if param.Explode != nil && !*param.Explode {
+			values = strings.Split(raw, ",")
+		}
+
+		items := make([]interface{}, 0, len(values))
+		for _, item := range values {
+			items = append(items, item)
+		}
+		return items, true
+	}
+
+	return raw, true
+}
+
+func (Validator *Validator) resolve(schema map[string]interface{}) map[string]interface{} {
+	for schema != nil {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			break
+		}
+		schema = Validator.schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+	}
+
+	return schema
+}
+
+func (Validator *Validator) check(schema map[string]interface{}, value interface{}, field string, fieldErrors *[]models.FieldError) {
+	schema = Validator.resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	fail := func(message string) {
+		*fieldErrors = append(*fieldErrors, models.FieldError{Field: field, Message: message})
+	}
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); !nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	if !hasType(schema["type"], value) {
+		fail("must be " + describeType(schema))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		allowed := make([]string, 0, len(enum))
+		for _, item := range enum {
+			allowed = append(allowed, fmt.Sprint(item))
+		}
+		fail("must be one of: " + strings.Join(allowed, ", "))
+		return
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				property, _ := properties[name.(string)].(map[string]interface{})
+				if readOnly, _ := Validator.resolve(property)["readOnly"].(bool); readOnly {
+					continue
+				}
+				if _, present := typed[name.(string)]; !present {
+					*fieldErrors = append(*fieldErrors, models.FieldError{Field: field + "." + name.(string), Message: "is required"})
+				}
+			}
+		}
+
+		for name, item := range typed {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			// Clients often send back whole objects; server-owned fields are ignored.
+			if readOnly, _ := Validator.resolve(property)["readOnly"].(bool); readOnly {
+				continue
+			}
+			Validator.check(property, item, field+"."+name, fieldErrors)
+		}
+	case []interface{}:
+		if minItems, ok := schema["minItems"].(float64); ok && float64(len(typed)) < minItems {
+			fail(fmt.Sprintf("must have at least %v items", minItems))
+		}
+		if maxItems, ok := schema["maxItems"].(float64); ok && float64(len(typed)) > maxItems {
+			fail(fmt.Sprintf("must have at most %v items", maxItems))
+		}
+
+		items, _ := schema["items"].(map[string]interface{})
+		for iter, item := range typed {
+			Validator.check(items, item, field+"["+strconv.Itoa(iter)+"]", fieldErrors)
+		}
+	case json.Number:
+		number, _ := typed.Float64()
+		if minimum, ok := schema["minimum"].(float64); ok && number < minimum {
+			fail(fmt.Sprintf("must be >= %v", minimum))
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && number > maximum {
+			fail(fmt.Sprintf("must be <= %v", maximum))
+		}
+	case string:
+		length := len([]rune(typed))
+		if minLength, ok := schema["minLength"].(float64); ok && float64(length) < minLength {
+			fail(fmt.Sprintf("must be at least %v characters", minLength))
+		}
+		if maxLength, ok := schema["maxLength"].(float64); ok && float64(length) > maxLength {
+			fail(fmt.Sprintf("must be at most %v characters", maxLength))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !Validator.patterns[pattern].MatchString(typed) {
+			fail("must match " + pattern)
+		}
+		if format, ok := schema["format"].(string); ok && !hasFormat(format, typed) {
+			fail("must be a valid " + format)
+		}
+	}
+}
+
+func hasType(schemaType interface{}, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := strconv.ParseInt(string(number), 10, 64)
+		return err == nil
+	}
+
+	return true
+}
+
+func describeType(schema map[string]interface{}) string {
+	switch schema["type"] {
+	case "object":
+		return "an object"
+	case "array":
+		return "an array"
+	case "integer":
+		return "an integer"
+	case "", nil:
+		return "a value"
+	}
+
+	return "a " + fmt.Sprint(schema["type"])
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, item := range enum {
+		if number, ok := value.(json.Number); ok {
+			if float, err := number.Float64(); err == nil && float == item {
+				return true
+			}
+			continue
+		}
+
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, value)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	case "uri":
+		parsed, err := url.Parse(value)
+		return err == nil && parsed.Scheme != "" && parsed.Host != ""
+	}
+
+	return true
+}
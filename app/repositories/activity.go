@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx"
+	"vk_db_project/app/models"
+)
+
+// activityQueries count rows per bucket over [$1, $2) truncated to $3, all
+// forums when $4 is NULL. A user joins the forums at large with their first
+// forumUsers row.
+var activityQueries = []struct {
+	sql   string
+	count func(*models.ActivityBucket) *int64
+}{
+	{
+		sql: "SELECT date_trunc($3, date AT TIME ZONE 'UTC') , COUNT(*) FROM threads " +
+			"WHERE date >= $1 AND date < $2 AND ($4::CITEXT IS NULL OR f_slug = $4) GROUP BY 1",
+		count: func(bucket *models.ActivityBucket) *int64 { return &bucket.Threads },
+	},
+	{
+		sql: "SELECT date_trunc($3, date AT TIME ZONE 'UTC') , COUNT(*) FROM messages " +
+			"WHERE date >= $1 AND date < $2 AND ($4::CITEXT IS NULL OR f_slug = $4) GROUP BY 1",
+		count: func(bucket *models.ActivityBucket) *int64 { return &bucket.Posts },
+	},
+	{
+		sql: "SELECT date_trunc($3, joined AT TIME ZONE 'UTC') , COUNT(*) FROM (" +
+			"SELECT u_nickname , MIN(date) AS joined FROM forumUsers WHERE $4::CITEXT IS NULL OR f_slug = $4 GROUP BY u_nickname" +
+			") J WHERE joined >= $1 AND joined < $2 GROUP BY 1",
+		count: func(bucket *models.ActivityBucket) *int64 { return &bucket.Users },
+	},
+	{
+		sql: "SELECT date_trunc($3, V.date AT TIME ZONE 'UTC') , COUNT(*) FROM voteThreads V JOIN threads T ON T.t_id = V.t_id " +
+			"WHERE V.date >= $1 AND V.date < $2 AND ($4::CITEXT IS NULL OR T.f_slug = $4) GROUP BY 1",
+		count: func(bucket *models.ActivityBucket) *int64 { return &bucket.Votes },
+	},
+}
+
+// backfillActivityDates dates the restored votes and forum users that came
+// without one: a user joined a forum with their first thread or post there,
+// and a vote is put on the day of its thread.
+func backfillActivityDates(db executor) error {
+	_, err := db.Exec("UPDATE forumUsers FU SET date = COALESCE(LEAST(" +
+		"(SELECT MIN(T.date) FROM threads T WHERE T.f_slug = FU.f_slug AND T.u_nickname = FU.u_nickname) , " +
+		"(SELECT MIN(M.date) FROM messages M WHERE M.f_slug = FU.f_slug AND M.u_nickname = FU.u_nickname)), now()) " +
+		"WHERE FU.date IS NULL")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE voteThreads V SET date = COALESCE(T.date, now()) FROM threads T WHERE T.t_id = V.t_id AND V.date IS NULL")
+
+	return err
+}
+
+// GetActivity fills in the counters of stats.Buckets, which the caller lays
+// out from stats.From to stats.To. An empty stats.Forum covers all forums.
+func (Forum ForumRepoImpl) GetActivity(stats models.ActivityStats) (models.ActivityStats, error) {
+	var forum *string
+	if stats.Forum != "" {
+		row := Forum.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", stats.Forum)
+		if err := row.Scan(&stats.Forum); err != nil {
+			return stats, err
+		}
+		forum = &stats.Forum
+	}
+
+	byStart := make(map[int64]*models.ActivityBucket, len(stats.Buckets))
+	for iter := range stats.Buckets {
+		byStart[stats.Buckets[iter].Start.Unix()] = &stats.Buckets[iter]
+	}
+
+	tx, err := Forum.database.BeginEx(context.Background(), &pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	for _, query := range activityQueries {
+		rows, err := tx.Query(query.sql, stats.From, stats.To, stats.Bucket, forum)
+		if err != nil {
+			return stats, err
+		}
+
+		for rows.Next() {
+			start := time.Time{}
+			count := int64(0)
+			if err = rows.Scan(&start, &count); err != nil {
+				rows.Close()
+				return stats, err
+			}
+
+			if bucket, ok := byStart[start.Unix()]; ok {
+				*query.count(bucket) = count
+			}
+		}
+
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
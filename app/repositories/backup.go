@@ -0,0 +1,467 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"vk_db_project/app/models"
+)
+
+type IBackupRepository interface {
+	Dump(func(string, interface{}) error) (map[string]int64, error)
+	Restore(func() (string, interface{}, error), func(string) []string) (map[string]int64, error)
+}
+
+type BackupRepoImpl struct {
+	database *pgx.ConnPool
+}
+
+func NewBackupRepoImpl(db *pgx.ConnPool) BackupRepoImpl {
+	return BackupRepoImpl{database: db}
+}
+
+type backupTable struct {
+	columns  string
+	copy     []string
+	sequence string
+	scan     func(*pgx.Rows) (interface{}, error)
+	values   func(interface{}) []interface{}
+}
+
+// restoredVersion is the ETag version of a restored user or forum. Backups
+// made before it was kept get the restore time, far above any counter, so no
+// ETag handed out before matches the restored row.
+func restoredVersion(version *int64) int64 {
+	if version == nil {
+		return time.Now().UnixNano() / int64(time.Microsecond)
+	}
+
+	return *version
+}
+
+var backupTables = map[string]backupTable{
+	"users": {
+		columns:  "u_id , nickname , fullname , email , about , auto_subscribe , COALESCE(version, 0)",
+		copy:     []string{"u_id", "nickname", "fullname", "email", "about", "auto_subscribe", "version"},
+		sequence: "u_id",
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupUser{}
+			err := rows.Scan(&row.Id, &row.Nickname, &row.Fullname, &row.Email, &row.About, &row.AutoSubscribe, &row.Version)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupUser)
+			return []interface{}{row.Id, row.Nickname, row.Fullname, row.Email, row.About, row.AutoSubscribe, restoredVersion(row.Version)}
+		},
+	},
+	"forums": {
+		columns:  "f_id , slug , title , u_nickname , COALESCE(message_counter, 0) , COALESCE(thread_counter, 0) , parent , COALESCE(version, 0)",
+		copy:     []string{"f_id", "slug", "title", "u_nickname", "message_counter", "thread_counter", "parent", "version"},
+		sequence: "f_id",
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupForum{}
+			err := rows.Scan(&row.Id, &row.Slug, &row.Title, &row.User, &row.Posts, &row.Threads, &row.Parent, &row.Version)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupForum)
+			return []interface{}{row.Id, row.Slug, row.Title, row.User, row.Posts, row.Threads, row.Parent, restoredVersion(row.Version)}
+		},
+	},
+	"threads": {
+		columns:  "t_id , slug , date , message , title , COALESCE(votes, 0) , COALESCE(version, 0) , COALESCE(locked, false) , tags , u_nickname , f_slug",
+		copy:     []string{"t_id", "slug", "date", "message", "title", "votes", "version", "locked", "tags", "u_nickname", "f_slug"},
+		sequence: "t_id",
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupThread{}
+			err := rows.Scan(&row.Id, &row.Slug, &row.Created, &row.Message, &row.Title, &row.Votes, &row.Version, &row.Locked, &row.Tags, &row.Author, &row.Forum)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupThread)
+			if row.Tags == nil {
+				row.Tags = []string{}
+			}
+			return []interface{}{row.Id, row.Slug, row.Created, row.Message, row.Title, row.Votes, row.Version, row.Locked, row.Tags, row.Author, row.Forum}
+		},
+	},
+	"voteThreads": {
+		columns:  "vt_id , t_id , COALESCE(counter, 0) , date , u_nickname",
+		copy:     []string{"vt_id", "t_id", "counter", "date", "u_nickname"},
+		sequence: "vt_id",
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupVote{}
+			err := rows.Scan(&row.Id, &row.Thread, &row.Voice, &row.Date, &row.Nickname)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupVote)
+			return []interface{}{row.Id, row.Thread, row.Voice, row.Date, row.Nickname}
+		},
+	},
+	"messages": {
+		columns:  "m_id , date , message , COALESCE(edit, false) , parent , path , COALESCE(version, 0) , u_nickname , f_slug , t_id",
+		copy:     []string{"m_id", "date", "message", "edit", "parent", "path", "version", "u_nickname", "f_slug", "t_id"},
+		sequence: "m_id",
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupPost{}
+			err := rows.Scan(&row.Id, &row.Created, &row.Message, &row.IsEdited, &row.Parent, &row.Path, &row.Version, &row.Author, &row.Forum, &row.Thread)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupPost)
+			return []interface{}{row.Id, row.Created, row.Message, row.IsEdited, row.Parent, row.Path, row.Version, row.Author, row.Forum, row.Thread}
+		},
+	},
+	"forumUsers": {
+		columns: "f_slug , u_nickname , date",
+		copy:    []string{"f_slug", "u_nickname", "date"},
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupForumUser{}
+			err := rows.Scan(&row.Forum, &row.Nickname, &row.Date)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupForumUser)
+			return []interface{}{row.Forum, row.Nickname, row.Date}
+		},
+	},
+	"webhooks": {
+		columns:  "w_id , f_slug , url , secret , events , date",
+		copy:     []string{"w_id", "f_slug", "url", "secret", "events", "date"},
+		sequence: "w_id",
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupWebhook{}
+			err := rows.Scan(&row.Id, &row.Forum, &row.Url, &row.Secret, &row.Events, &row.Created)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupWebhook)
+			if row.Events == nil {
+				row.Events = []string{}
+			}
+			return []interface{}{row.Id, row.Forum, row.Url, row.Secret, row.Events, row.Created}
+		},
+	},
+	"subscriptions": {
+		columns:  "s_id , u_nickname , t_id , last_seen , date",
+		copy:     []string{"s_id", "u_nickname", "t_id", "last_seen", "date"},
+		sequence: "s_id",
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupSubscription{}
+			err := rows.Scan(&row.Id, &row.Nickname, &row.Thread, &row.LastSeen, &row.Created)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupSubscription)
+			return []interface{}{row.Id, row.Nickname, row.Thread, row.LastSeen, row.Created}
+		},
+	},
+	"notifications": {
+		columns:  "n_id , u_nickname , kind , actor , m_id , t_id , f_slug , is_read , date",
+		copy:     []string{"n_id", "u_nickname", "kind", "actor", "m_id", "t_id", "f_slug", "is_read", "date"},
+		sequence: "n_id",
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupNotification{}
+			err := rows.Scan(&row.Id, &row.Nickname, &row.Kind, &row.Actor, &row.Post, &row.Thread, &row.Forum, &row.IsRead, &row.Created)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupNotification)
+			return []interface{}{row.Id, row.Nickname, row.Kind, row.Actor, row.Post, row.Thread, row.Forum, row.IsRead, row.Created}
+		},
+	},
+	"importedMessages": {
+		columns: "message_id , f_slug , t_id , m_id",
+		copy:    []string{"message_id", "f_slug", "t_id", "m_id"},
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupImportedMessage{}
+			err := rows.Scan(&row.MessageId, &row.Forum, &row.Thread, &row.Post)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupImportedMessage)
+			return []interface{}{row.MessageId, row.Forum, row.Thread, row.Post}
+		},
+	},
+	"forumRoles": {
+		columns: "f_slug , u_nickname , role , granted_by , date",
+		copy:    []string{"f_slug", "u_nickname", "role", "granted_by", "date"},
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupForumRole{}
+			err := rows.Scan(&row.Forum, &row.Nickname, &row.Role, &row.GrantedBy, &row.Granted)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupForumRole)
+			return []interface{}{row.Forum, row.Nickname, row.Role, row.GrantedBy, row.Granted}
+		},
+	},
+	"forumBans": {
+		columns: "f_slug , u_nickname , moderator , reason , date",
+		copy:    []string{"f_slug", "u_nickname", "moderator", "reason", "date"},
+		scan: func(rows *pgx.Rows) (interface{}, error) {
+			row := &models.BackupForumBan{}
+			err := rows.Scan(&row.Forum, &row.Nickname, &row.Moderator, &row.Reason, &row.Created)
+			return row, err
+		},
+		values: func(value interface{}) []interface{} {
+			row := value.(*models.BackupForumBan)
+			return []interface{}{row.Forum, row.Nickname, row.Moderator, row.Reason, row.Created}
+		},
+	},
+}
+
+// Dump streams every row of the backup tables to visit from one repeatable
+// read snapshot, so counters and rows are consistent with each other.
+func (Backup BackupRepoImpl) Dump(visit func(string, interface{}) error) (map[string]int64, error) {
+	counts := make(map[string]int64)
+
+	tx, err := Backup.database.BeginEx(context.Background(), &pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, name := range models.BackupTables {
+		table := backupTables[name]
+
+		order := table.sequence
+		if order == "" {
+			order = table.columns
+		}
+
+		rows, err := tx.Query("SELECT " + table.columns + " FROM " + name + " ORDER BY " + order)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			row, err := table.scan(rows)
+			if err == nil {
+				err = visit(name, row)
+			}
+
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			counts[name]++
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return counts, nil
+}
+
+// restoreSource feeds one table to CopyFrom and stops at the first row of the
+// next table, keeping it for the following copy.
+type restoreSource struct {
+	next    func() (string, interface{}, error)
+	table   string
+	pending interface{}
+	values  []interface{}
+	done    bool
+	err     error
+}
+
+func (source *restoreSource) Next() bool {
+	if source.pending != nil {
+		source.values = backupTables[source.table].values(source.pending)
+		source.pending = nil
+		return true
+	}
+
+	table, row, err := source.next()
+	if err == io.EOF {
+		source.done = true
+		return false
+	}
+
+	if err != nil {
+		source.err = err
+		return false
+	}
+
+	if table != source.table {
+		source.table = table
+		source.pending = row
+		return false
+	}
+
+	source.values = backupTables[table].values(row)
+	return true
+}
+
+func (source *restoreSource) Values() ([]interface{}, error) {
+	return source.values, nil
+}
+
+func (source *restoreSource) Err() error {
+	return source.err
+}
+
+// Restore replaces the contents of the backup tables with the rows returned by
+// next, which reports io.EOF after the last one. Ids are copied as they are,
+// so the updater trigger is disabled and sequences are moved past them; roles
+// come from the backup too, so the forum owner trigger is disabled as well.
+// Mentions are rebuilt from the messages with extract, which returns the
+// candidate nicknames of a message.
+func (Backup BackupRepoImpl) Restore(next func() (string, interface{}, error), extract func(string) []string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+
+	tx, err := Backup.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for iter := len(models.BackupTables) - 1; iter >= 0; iter-- {
+		if _, err = tx.Exec("DELETE FROM " + models.BackupTables[iter]); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err = tx.Exec("ALTER TABLE messages DISABLE TRIGGER u_updater"); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec("ALTER TABLE forums DISABLE TRIGGER u_forum_owner"); err != nil {
+		return nil, err
+	}
+
+	source := &restoreSource{next: next}
+	table, row, err := next()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	source.table, source.pending, source.done = table, row, err == io.EOF
+
+	for !source.done {
+		name := source.table
+		spec, ok := backupTables[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown table %q in backup", name)
+		}
+
+		if _, copied := counts[name]; copied {
+			return nil, fmt.Errorf("rows of table %q are not contiguous in backup", name)
+		}
+
+		// Unquoted names in db.sql are folded to lower case, while CopyFrom quotes them.
+		copied, err := tx.CopyFrom(pgx.Identifier{strings.ToLower(name)}, spec.copy, source)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[name] = int64(copied)
+	}
+
+	for _, name := range models.BackupTables {
+		sequence := backupTables[name].sequence
+		if sequence == "" {
+			continue
+		}
+
+		_, err = tx.Exec("SELECT setval(pg_get_serial_sequence('" + name + "', '" + sequence + "'), COALESCE(MAX(" + sequence + "), 1), MAX(" + sequence + ") IS NOT NULL) FROM " + name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Votes of old backups get their date first, the rollup keys by it.
+	if err = backfillActivityDates(tx); err != nil {
+		return nil, err
+	}
+
+	if err = rebuildContributions(tx); err != nil {
+		return nil, err
+	}
+
+	if err = rebuildForumTotals(tx); err != nil {
+		return nil, err
+	}
+
+	if err = rebuildMentions(tx, extract); err != nil {
+		return nil, err
+	}
+
+	if err = scoreThreadRanks(tx, nil); err != nil {
+		return nil, err
+	}
+
+	// Backups made before roles were kept only have the owners in forums.
+	_, err = tx.Exec("INSERT INTO forumRoles (f_slug , u_nickname , role) SELECT slug , u_nickname , $1 FROM forums WHERE u_nickname IS NOT NULL "+
+		"ON CONFLICT (f_slug , u_nickname) DO UPDATE SET role = EXCLUDED.role", models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec("ALTER TABLE forums ENABLE TRIGGER u_forum_owner"); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec("ALTER TABLE messages ENABLE TRIGGER u_updater"); err != nil {
+		return nil, err
+	}
+
+	return counts, tx.Commit()
+}
+
+// mentionBatch is how many messages rebuildMentions reads at a time.
+const mentionBatch = 10000
+
+// rebuildMentions stores the mentions of every message again, without
+// notifying anyone: notifications are restored from the backup.
+func rebuildMentions(tx *pgx.Tx, extract func(string) []string) error {
+	lastId := int64(0)
+	for {
+		rows, err := tx.Query("SELECT m_id , COALESCE(message, '') FROM messages WHERE m_id > $1 ORDER BY m_id LIMIT $2", lastId, mentionBatch)
+		if err != nil {
+			return err
+		}
+
+		read := 0
+		postIds := make([]int64, 0)
+		nicknames := make([]string, 0)
+		for rows.Next() {
+			message := ""
+			if err = rows.Scan(&lastId, &message); err != nil {
+				rows.Close()
+				return err
+			}
+
+			read++
+			for _, nickname := range extract(message) {
+				postIds = append(postIds, lastId)
+				nicknames = append(nicknames, nickname)
+			}
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		if len(postIds) != 0 {
+			_, err = tx.Exec("INSERT INTO mentions (m_id , u_nickname) SELECT C.m_id , U.nickname "+
+				"FROM unnest($1::BIGINT[], $2::TEXT[]) AS C (m_id , nick) JOIN users U ON U.nickname = C.nick::CITEXT ON CONFLICT DO NOTHING", postIds, nicknames)
+			if err != nil {
+				return err
+			}
+		}
+
+		if read < mentionBatch {
+			return nil
+		}
+	}
+}
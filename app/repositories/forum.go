@@ -6,15 +6,25 @@ import (
 	"time"
 
 	"github.com/jackc/pgx"
+	forumErrors "vk_db_project/app/errors"
 	"vk_db_project/app/models"
 )
 
 type IForumRepository interface {
 	CreateNewForum(models.Forum) (models.Forum, error)
 	GetForum(string) (models.Forum, error)
+	GetForumsBySlugs([]string) ([]models.Forum, error)
 	CreateThread(models.Thread) (models.Thread, error)
-	GetThreads(models.Forum, int, string, bool) ([]models.Thread, error)
+	GetThreads(models.Forum, int, string, bool, models.ThreadFilter) ([]models.Thread, error)
 	GetForumUsers(string, int, string, bool) ([]models.UserModel, error)
+	GetLeaderboard(string, string, int, int) ([]models.LeaderboardEntry, error)
+	RefreshThreadRanks(int) (int64, error)
+	GetRankedThreads(string, string, int, int, string, models.ThreadFilter) ([]models.Thread, string, error)
+	GetTags(string, int) ([]models.TagCount, error)
+	GetActivity(models.ActivityStats) (models.ActivityStats, error)
+	GetChildren(string) ([]models.Forum, error)
+	MoveForum(string, string) (models.Forum, error)
+	ClearForum(string) error
 }
 
 type ForumRepoImpl struct {
@@ -34,10 +44,17 @@ func (Forum ForumRepoImpl) CreateNewForum(forum models.Forum) (models.Forum, err
 		return forum, err
 	}
 
-	_, err = Forum.database.Exec("INSERT INTO forums (slug , title, u_nickname) VALUES($1 , $2 , $3)", forum.Slug, forum.Title, forum.User)
+	if forum.Parent != "" {
+		row = Forum.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", forum.Parent)
+		if err = row.Scan(&forum.Parent); err != nil {
+			return forum, forumErrors.ParentNotFound
+		}
+	}
+
+	_, err = Forum.database.Exec("INSERT INTO forums (slug , title, u_nickname , parent) VALUES($1 , $2 , $3 , NULLIF($4, ''))", forum.Slug, forum.Title, forum.User, forum.Parent)
 	if err != nil {
-		row := Forum.database.QueryRow("SELECT u_nickname , title , slug FROM forums WHERE slug = $1;", forum.Slug)
-		row.Scan(&forum.User, &forum.Title, &forum.Slug)
+		row := Forum.database.QueryRow("SELECT u_nickname , title , slug , COALESCE(parent, '') FROM forums WHERE slug = $1;", forum.Slug)
+		row.Scan(&forum.User, &forum.Title, &forum.Slug, &forum.Parent)
 		return forum, err
 	}
 
@@ -47,9 +64,9 @@ func (Forum ForumRepoImpl) CreateNewForum(forum models.Forum) (models.Forum, err
 func (Forum ForumRepoImpl) GetForum(slug string) (models.Forum, error) {
 
 	forumData := new(models.Forum)
-	row := Forum.database.QueryRow("SELECT slug , title, u_nickname , message_counter , thread_counter FROM forums WHERE slug = $1", slug)
+	row := Forum.database.QueryRow("SELECT slug , title, u_nickname , message_counter , thread_counter , version , COALESCE(parent, '') , total_messages , total_threads FROM forums WHERE slug = $1", slug)
 
-	err := row.Scan(&forumData.Slug, &forumData.Title, &forumData.User, &forumData.Posts, &forumData.Threads)
+	err := row.Scan(&forumData.Slug, &forumData.Title, &forumData.User, &forumData.Posts, &forumData.Threads, &forumData.Version, &forumData.Parent, &forumData.TotalPosts, &forumData.TotalThreads)
 	if err != nil {
 		return *forumData, err
 	}
@@ -57,6 +74,25 @@ func (Forum ForumRepoImpl) GetForum(slug string) (models.Forum, error) {
 	return *forumData, nil
 }
 
+func (Forum ForumRepoImpl) GetForumsBySlugs(slugs []string) ([]models.Forum, error) {
+	rows, err := Forum.database.Query("SELECT slug , title, u_nickname , message_counter , thread_counter , COALESCE(parent, '') , total_messages , total_threads FROM forums WHERE slug = ANY($1::TEXT[]::CITEXT[])", slugs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := make([]models.Forum, 0, len(slugs))
+	for rows.Next() {
+		forum := models.Forum{}
+		if err = rows.Scan(&forum.Slug, &forum.Title, &forum.User, &forum.Posts, &forum.Threads, &forum.Parent, &forum.TotalPosts, &forum.TotalThreads); err != nil {
+			return nil, err
+		}
+		forums = append(forums, forum)
+	}
+
+	return forums, rows.Err()
+}
+
 func (Forum ForumRepoImpl) CreateThread(thread models.Thread) (models.Thread, error) {
 
 	tx, err := Forum.database.Begin()
@@ -101,6 +137,13 @@ func (Forum ForumRepoImpl) CreateThread(thread models.Thread) (models.Thread, er
 		valuesQuery += " $" + strconv.Itoa(valuesCounter) + ","
 	}
 
+	if len(thread.Tags) != 0 {
+		insertColumns += " tags,"
+		insertValues = append(insertValues, thread.Tags)
+		valuesCounter++
+		valuesQuery += " $" + strconv.Itoa(valuesCounter) + ","
+	}
+
 	if thread.Created.String() != "" {
 		insertColumns += " date,"
 		insertValues = append(insertValues, thread.Created)
@@ -121,19 +164,40 @@ func (Forum ForumRepoImpl) CreateThread(thread models.Thread) (models.Thread, er
 
 	if err != nil {
 		tx.Rollback()
-		row = Forum.database.QueryRow("SELECT u_nickname , date ,f_slug , t_id , message , slug , title , votes FROM threads WHERE slug = $1", thread.Slug)
-		err = row.Scan(&thread.Author, &thread.Created, &thread.Forum, &thread.Id, &thread.Message, &thread.Slug, &thread.Title, &thread.Votes)
+		row = Forum.database.QueryRow("SELECT u_nickname , date ,f_slug , t_id , message , slug , title , votes , tags FROM threads WHERE slug = $1", thread.Slug)
+		err = row.Scan(&thread.Author, &thread.Created, &thread.Forum, &thread.Id, &thread.Message, &thread.Slug, &thread.Title, &thread.Votes, &thread.Tags)
 		return thread, errors.New("thread already exist")
 	}
 
-	_, err = tx.Exec("INSERT INTO forumUsers (f_slug,u_nickname) VALUES ($1,$2) ON CONFLICT (f_slug,u_nickname) DO NOTHING", thread.Forum, thread.Author)
-	_, err = tx.Exec("UPDATE forums SET thread_counter = thread_counter +1 WHERE slug = $1", thread.Forum)
+	_, err = tx.Exec("INSERT INTO forumUsers (f_slug,u_nickname,date) VALUES ($1,$2,$3) ON CONFLICT (f_slug,u_nickname) DO NOTHING", thread.Forum, thread.Author, thread.Created)
+	_, err = tx.Exec("UPDATE forums SET thread_counter = thread_counter +1 , version = version + 1 WHERE slug = $1", thread.Forum)
+
+	err = addThreadContribution(tx, thread.Id)
+	if err == nil && thread.ImportedId != "" {
+		err = saveImported(tx, thread.ImportedId, thread.Forum, thread.Id, 0)
+	}
+	if err != nil {
+		tx.Rollback()
+		return thread, err
+	}
+
+	err = scoreThreadRanks(tx, []int64{int64(thread.Id)})
+	if err != nil {
+		tx.Rollback()
+		return thread, err
+	}
+
+	err = enqueueWebhookEvents(tx, thread.Forum, models.EventThreadCreated, thread)
+	if err != nil {
+		tx.Rollback()
+		return thread, err
+	}
 
 	tx.Commit()
 	return thread, nil
 }
 
-func (Forum ForumRepoImpl) GetThreads(forum models.Forum, limit int, since string, sort bool) ([]models.Thread, error) {
+func (Forum ForumRepoImpl) GetThreads(forum models.Forum, limit int, since string, sort bool, filter models.ThreadFilter) ([]models.Thread, error) {
 
 	tx, err := Forum.database.Begin()
 
@@ -158,12 +222,25 @@ func (Forum ForumRepoImpl) GetThreads(forum models.Forum, limit int, since strin
 	}
 
 	var rowThreads *pgx.Rows
-	selectRow := "SELECT t_id , date , message , title , votes , slug , f_slug , u_nickname FROM threads T "
+	var forumStatus string
+	var forumValue interface{}
+
+	selectRow := "SELECT t_id , date , message , title , votes , slug , f_slug , u_nickname , tags FROM threads T "
 	if since != "" {
-		sinceStatus := "WHERE f_slug = $3 AND date" + sorter + "=$2" + " "
-		rowThreads, err = tx.Query(selectRow+sinceStatus+" ORDER BY date "+orderStatus+" LIMIT $1", limit, since, forum.Slug)
+		forumStatus, forumValue, err = forumCondition(tx, "f_slug", forum.Slug, filter.Recursive, 3)
+		if err != nil {
+			return nil, err
+		}
+		tagStatus, tagValues := tagCondition(filter, 4)
+		sinceStatus := "WHERE " + forumStatus + " AND date" + sorter + "=$2" + " "
+		rowThreads, err = tx.Query(selectRow+sinceStatus+tagStatus+" ORDER BY date "+orderStatus+" LIMIT $1", append([]interface{}{limit, since, forumValue}, tagValues...)...)
 	} else {
-		rowThreads, err = tx.Query(selectRow+"WHERE f_slug = $2 "+"ORDER BY date "+orderStatus+" LIMIT $1", limit, forum.Slug)
+		forumStatus, forumValue, err = forumCondition(tx, "f_slug", forum.Slug, filter.Recursive, 2)
+		if err != nil {
+			return nil, err
+		}
+		tagStatus, tagValues := tagCondition(filter, 3)
+		rowThreads, err = tx.Query(selectRow+"WHERE "+forumStatus+" "+tagStatus+"ORDER BY date "+orderStatus+" LIMIT $1", append([]interface{}{limit, forumValue}, tagValues...)...)
 	}
 
 	if err != nil {
@@ -177,7 +254,7 @@ func (Forum ForumRepoImpl) GetThreads(forum models.Forum, limit int, since strin
 		for rowThreads.Next() {
 			thread := new(models.Thread)
 			var threadSlug *string
-			err = rowThreads.Scan(&thread.Id, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &threadSlug, &thread.Forum, &thread.Author)
+			err = rowThreads.Scan(&thread.Id, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &threadSlug, &thread.Forum, &thread.Author, &thread.Tags)
 
 			if threadSlug != nil {
 				thread.Slug = *threadSlug
@@ -267,3 +344,18 @@ func (Forum ForumRepoImpl) GetForumUsers(slug string, limit int, since string, d
 
 	return users, nil
 }
+
+// ClearForum deletes the forum together with its threads, posts and votes.
+// Users stay, since they may be active in other forums.
+func (Forum ForumRepoImpl) ClearForum(slug string) error {
+	tag, err := Forum.database.Exec("DELETE FROM forums WHERE slug = $1", slug)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"github.com/jackc/pgx"
+)
+
+type IImportRepository interface {
+	GetImported(string, string) (int, int64, error)
+	GetNicknameByEmail(string) (string, error)
+}
+
+type ImportRepoImpl struct {
+	database *pgx.ConnPool
+}
+
+func NewImportRepoImpl(db *pgx.ConnPool) ImportRepoImpl {
+	return ImportRepoImpl{database: db}
+}
+
+// GetImported returns the thread and post created for a Message-ID in a
+// forum. The post is zero for messages that started a thread.
+func (Import ImportRepoImpl) GetImported(forum string, messageId string) (int, int64, error) {
+	threadId := 0
+	postId := int64(0)
+
+	row := Import.database.QueryRow("SELECT t_id , m_id FROM importedMessages WHERE f_slug = $1 AND message_id = $2", forum, messageId)
+	err := row.Scan(&threadId, &postId)
+
+	return threadId, postId, err
+}
+
+// saveImported records the Message-ID of a mail in the transaction that
+// creates its thread or post, so an import that stops halfway never leaves
+// content it would create again. A second import of the same mail into the
+// forum fails on the primary key and rolls its content back.
+func saveImported(db executor, messageId string, forum string, threadId int, postId int64) error {
+	_, err := db.Exec("INSERT INTO importedMessages (message_id , f_slug , t_id , m_id) VALUES ($1 , $2 , $3 , $4)",
+		messageId, forum, threadId, postId)
+
+	return err
+}
+
+func (Import ImportRepoImpl) GetNicknameByEmail(email string) (string, error) {
+	nickname := ""
+	row := Import.database.QueryRow("SELECT nickname FROM users WHERE email = $1", email)
+	err := row.Scan(&nickname)
+
+	return nickname, err
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"strconv"
+	"time"
+
+	"vk_db_project/app/models"
+)
+
+// leaderboardColumns maps a leaderboard metric to its forumContributions
+// column; only these names ever reach the query text.
+var leaderboardColumns = map[string]string{
+	models.MetricPosts:   "posts",
+	models.MetricThreads: "threads",
+	models.MetricVotes:   "votes",
+}
+
+// addThreadContribution counts a new thread for its author on the day the
+// thread was created. Every write keys by the date of the row, as
+// rebuildContributions and the deletes do, so threads with an imported or
+// client supplied date stay on their own day.
+func addThreadContribution(db executor, threadId int) error {
+	_, err := db.Exec("INSERT INTO forumContributions (f_slug , day , u_nickname , threads) "+
+		"SELECT f_slug , date::DATE , u_nickname , 1 FROM threads WHERE t_id = $1 "+
+		"ON CONFLICT (f_slug , day , u_nickname) DO UPDATE SET threads = forumContributions.threads + EXCLUDED.threads", threadId)
+
+	return err
+}
+
+// moveVoteContribution credits a changed vote to the thread author: the
+// previous voice comes off the day it was cast and the new one lands on
+// today, the date the vote row now carries.
+func moveVoteContribution(db executor, threadId int, previous int, previousDate *time.Time, voice int) error {
+	_, err := db.Exec("INSERT INTO forumContributions (f_slug , day , u_nickname , votes) "+
+		"SELECT T.f_slug , D.day , T.u_nickname , SUM(D.votes) FROM threads T , "+
+		"(VALUES ($2::TIMESTAMPTZ::DATE , -$3::INT) , (now()::DATE , $4::INT)) D (day , votes) "+
+		"WHERE T.t_id = $1 AND D.day IS NOT NULL AND D.votes <> 0 GROUP BY 1 , 2 , 3 "+
+		"ON CONFLICT (f_slug , day , u_nickname) DO UPDATE SET votes = forumContributions.votes + EXCLUDED.votes",
+		threadId, previousDate, previous, voice)
+
+	return err
+}
+
+// addPostContributions counts a batch of new posts, one row per author and
+// day the posts were written.
+func addPostContributions(db executor, postIds []int64) error {
+	if len(postIds) == 0 {
+		return nil
+	}
+
+	_, err := db.Exec("INSERT INTO forumContributions (f_slug , day , u_nickname , posts) "+
+		"SELECT f_slug , date::DATE , u_nickname , COUNT(*) FROM messages WHERE m_id = ANY($1) GROUP BY f_slug , date::DATE , u_nickname "+
+		"ON CONFLICT (f_slug , day , u_nickname) DO UPDATE SET posts = forumContributions.posts + EXCLUDED.posts", postIds)
+
+	return err
+}
+
+// removePostContributions takes posts that are about to be deleted off the
+// rollup, on the day they were written.
+func removePostContributions(db executor, postIds []int64) error {
+	if len(postIds) == 0 {
+		return nil
+	}
+
+	_, err := db.Exec("UPDATE forumContributions C SET posts = C.posts - M.posts FROM ("+
+		"SELECT f_slug , date::DATE AS day , u_nickname , COUNT(*) AS posts FROM messages WHERE m_id = ANY($1) GROUP BY 1 , 2 , 3"+
+		") M WHERE C.f_slug = M.f_slug AND C.day = M.day AND C.u_nickname = M.u_nickname", postIds)
+
+	return err
+}
+
+// rebuildContributions recomputes the rollup from restored rows. Posts and
+// threads land on the day they were created and votes on the day they were
+// cast.
+func rebuildContributions(db executor) error {
+	_, err := db.Exec("DELETE FROM forumContributions")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO forumContributions (f_slug , day , u_nickname , posts , threads , votes) " +
+		"SELECT f_slug , day , u_nickname , SUM(posts) , SUM(threads) , SUM(votes) FROM (" +
+		"SELECT f_slug , date::DATE AS day , u_nickname , COUNT(*) AS posts , 0 AS threads , 0 AS votes FROM messages GROUP BY 1 , 2 , 3 " +
+		"UNION ALL " +
+		"SELECT f_slug , date::DATE , u_nickname , 0 , COUNT(*) , 0 FROM threads GROUP BY 1 , 2 , 3 " +
+		"UNION ALL " +
+		"SELECT T.f_slug , V.date::DATE , T.u_nickname , 0 , 0 , SUM(V.counter) FROM voteThreads V JOIN threads T ON T.t_id = V.t_id GROUP BY 1 , 2 , 3" +
+		") C GROUP BY f_slug , day , u_nickname")
+
+	return err
+}
+
+// GetLeaderboard ranks the users of a forum by metric over the last days
+// days, today included; zero days means all time. Users with a zero value
+// are left out and equal values share a rank.
+func (Forum ForumRepoImpl) GetLeaderboard(slug string, metric string, days int, limit int) ([]models.LeaderboardEntry, error) {
+	row := Forum.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err := row.Scan(&slug); err != nil {
+		return nil, err
+	}
+
+	column, ok := leaderboardColumns[metric]
+	if !ok {
+		column = leaderboardColumns[models.MetricPosts]
+	}
+
+	selectQuery := "SELECT RANK() OVER (ORDER BY SUM(C." + column + ") DESC) , U.nickname , SUM(C." + column + ") " +
+		"FROM forumContributions C JOIN users U ON U.nickname = C.u_nickname WHERE C.f_slug = $1 "
+	selectValues := []interface{}{slug}
+
+	if days != 0 {
+		selectValues = append(selectValues, days)
+		selectQuery += "AND C.day > CURRENT_DATE - $" + strconv.Itoa(len(selectValues)) + "::INT "
+	}
+
+	selectQuery += "GROUP BY U.nickname HAVING SUM(C." + column + ") <> 0 ORDER BY 1 , 2"
+	if limit != 0 {
+		selectValues = append(selectValues, limit)
+		selectQuery += " LIMIT $" + strconv.Itoa(len(selectValues))
+	}
+
+	rows, err := Forum.database.Query(selectQuery, selectValues...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]models.LeaderboardEntry, 0)
+	for rows.Next() {
+		entry := models.LeaderboardEntry{}
+		if err = rows.Scan(&entry.Rank, &entry.Nickname, &entry.Value); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
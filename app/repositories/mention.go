@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"strconv"
+
+	"github.com/jackc/pgx"
+	"vk_db_project/app/models"
+)
+
+type IMentionRepository interface {
+	GetMentions(string, int, int64) ([]models.Post, error)
+}
+
+type MentionRepoImpl struct {
+	database *pgx.ConnPool
+}
+
+func NewMentionRepoImpl(db *pgx.ConnPool) MentionRepoImpl {
+	return MentionRepoImpl{database: db}
+}
+
+type querier interface {
+	Query(string, ...interface{}) (*pgx.Rows, error)
+}
+
+// syncMentions replaces the stored mentions of post with the candidates that
+// are existing nicknames. Newly mentioned users other than the author get a
+// notification dated like the post, unless the post already notified them,
+// and users dropped by an edit lose their unread one.
+func syncMentions(tx *pgx.Tx, post *models.Post, candidates []string) error {
+	post.Mentions = make([]string, 0)
+
+	if len(candidates) != 0 {
+		rows, err := tx.Query("SELECT nickname FROM users WHERE nickname = ANY($1::TEXT[]::CITEXT[]) ORDER BY nickname", candidates)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			nickname := ""
+			if err = rows.Scan(&nickname); err != nil {
+				rows.Close()
+				return err
+			}
+			post.Mentions = append(post.Mentions, nickname)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec("DELETE FROM notifications WHERE m_id = $1 AND kind = $2 AND is_read = false AND u_nickname IN "+
+		"(SELECT u_nickname FROM mentions WHERE m_id = $1 AND NOT u_nickname = ANY($3::TEXT[]::CITEXT[]))", post.Id, models.NotificationMention, post.Mentions)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM mentions WHERE m_id = $1 AND NOT u_nickname = ANY($2::TEXT[]::CITEXT[])", post.Id, post.Mentions)
+	if err != nil || len(post.Mentions) == 0 {
+		return err
+	}
+
+	_, err = tx.Exec("WITH added AS (INSERT INTO mentions (m_id , u_nickname) SELECT $1 , unnest($2::TEXT[]::CITEXT[]) ON CONFLICT DO NOTHING RETURNING u_nickname) "+
+		"INSERT INTO notifications (u_nickname , kind , actor , m_id , t_id , f_slug , date) "+
+		"SELECT A.u_nickname , $3 , M.u_nickname , M.m_id , M.t_id , M.f_slug , M.date FROM added A JOIN messages M ON M.m_id = $1 "+
+		"WHERE A.u_nickname <> M.u_nickname "+
+		"AND NOT EXISTS (SELECT 1 FROM notifications N WHERE N.u_nickname = A.u_nickname AND N.m_id = M.m_id)", post.Id, post.Mentions, models.NotificationMention)
+
+	return err
+}
+
+// loadMentions fills Mentions for a page of posts with a single query.
+func loadMentions(db querier, posts []models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postIds := make([]int64, 0, len(posts))
+	positions := make(map[int64]int, len(posts))
+	for iter, _ := range posts {
+		postIds = append(postIds, posts[iter].Id)
+		positions[posts[iter].Id] = iter
+	}
+
+	rows, err := db.Query("SELECT m_id , u_nickname FROM mentions WHERE m_id = ANY($1) ORDER BY m_id , u_nickname", postIds)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		postId := int64(0)
+		nickname := ""
+		if err = rows.Scan(&postId, &nickname); err != nil {
+			return err
+		}
+
+		iter := positions[postId]
+		posts[iter].Mentions = append(posts[iter].Mentions, nickname)
+	}
+
+	return rows.Err()
+}
+
+func (Mention MentionRepoImpl) GetMentions(nickname string, limit int, since int64) ([]models.Post, error) {
+	row := Mention.database.QueryRow("SELECT nickname FROM users WHERE nickname = $1", nickname)
+	if err := row.Scan(&nickname); err != nil {
+		return nil, err
+	}
+
+	selectQuery := "SELECT M.m_id , M.date , M.message , M.edit , M.parent , M.u_nickname , M.t_id , M.f_slug FROM mentions MN " +
+		"JOIN messages M ON M.m_id = MN.m_id WHERE MN.u_nickname = $1 "
+	selectValues := []interface{}{nickname}
+
+	if since != 0 {
+		selectValues = append(selectValues, since)
+		selectQuery += "AND MN.m_id < $" + strconv.Itoa(len(selectValues)) + " "
+	}
+
+	selectQuery += "ORDER BY MN.m_id DESC"
+	if limit != 0 {
+		selectValues = append(selectValues, limit)
+		selectQuery += " LIMIT $" + strconv.Itoa(len(selectValues))
+	}
+
+	rows, err := Mention.database.Query(selectQuery, selectValues...)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]models.Post, 0)
+	for rows.Next() {
+		post := models.Post{}
+		err = rows.Scan(&post.Id, &post.Created, &post.Message, &post.IsEdited, &post.Parent, &post.Author, &post.Thread, &post.Forum)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		posts = append(posts, post)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, loadMentions(Mention.database, posts)
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"strconv"
+
+	"github.com/jackc/pgx"
+	"vk_db_project/app/models"
+)
+
+type INotificationRepository interface {
+	GetNotifications(string, int, int64, bool) ([]models.Notification, error)
+	MarkRead(string, int64) (models.NotificationsRead, error)
+}
+
+type NotificationRepoImpl struct {
+	database *pgx.ConnPool
+}
+
+func NewNotificationRepoImpl(db *pgx.ConnPool) NotificationRepoImpl {
+	return NotificationRepoImpl{database: db}
+}
+
+// notifyPostRecipients tells parent authors about replies and thread authors
+// about new posts in their threads. A thread author who is also the parent
+// author only gets the reply, and nobody is notified about their own posts.
+func notifyPostRecipients(db executor, postIds []int64) error {
+	if len(postIds) == 0 {
+		return nil
+	}
+
+	_, err := db.Exec("INSERT INTO notifications (u_nickname , kind , actor , m_id , t_id , f_slug , date) "+
+		"SELECT P.u_nickname , $2::TEXT , M.u_nickname , M.m_id , M.t_id , M.f_slug , M.date FROM messages M JOIN messages P ON P.m_id = M.parent "+
+		"WHERE M.m_id = ANY($1) AND P.u_nickname <> M.u_nickname "+
+		"UNION ALL "+
+		"SELECT T.u_nickname , $3::TEXT , M.u_nickname , M.m_id , M.t_id , M.f_slug , M.date FROM messages M JOIN threads T ON T.t_id = M.t_id "+
+		"WHERE M.m_id = ANY($1) AND T.u_nickname <> M.u_nickname "+
+		"AND NOT EXISTS (SELECT 1 FROM messages P WHERE P.m_id = M.parent AND P.u_nickname = T.u_nickname) "+
+		"ORDER BY 4", postIds, models.NotificationReply, models.NotificationThreadPost)
+
+	return err
+}
+
+func (Notification NotificationRepoImpl) GetNotifications(nickname string, limit int, since int64, unread bool) ([]models.Notification, error) {
+	row := Notification.database.QueryRow("SELECT nickname FROM users WHERE nickname = $1", nickname)
+	if err := row.Scan(&nickname); err != nil {
+		return nil, err
+	}
+
+	selectQuery := "SELECT n_id , kind , actor , m_id , t_id , f_slug , is_read , date FROM notifications WHERE u_nickname = $1 "
+	selectValues := []interface{}{nickname}
+
+	if unread {
+		selectQuery += "AND is_read = false "
+	}
+
+	if since != 0 {
+		selectValues = append(selectValues, since)
+		selectQuery += "AND n_id < $" + strconv.Itoa(len(selectValues)) + " "
+	}
+
+	selectQuery += "ORDER BY n_id DESC"
+	if limit != 0 {
+		selectValues = append(selectValues, limit)
+		selectQuery += " LIMIT $" + strconv.Itoa(len(selectValues))
+	}
+
+	rows, err := Notification.database.Query(selectQuery, selectValues...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]models.Notification, 0)
+	for rows.Next() {
+		notification := models.Notification{}
+		err = rows.Scan(&notification.Id, &notification.Kind, &notification.Author, &notification.Post, &notification.Thread,
+			&notification.Forum, &notification.Read, &notification.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
+}
+
+// MarkRead marks every notification up to and including upTo as read; zero
+// marks all of them.
+func (Notification NotificationRepoImpl) MarkRead(nickname string, upTo int64) (models.NotificationsRead, error) {
+	answer := models.NotificationsRead{UpTo: upTo}
+
+	row := Notification.database.QueryRow("SELECT nickname FROM users WHERE nickname = $1", nickname)
+	if err := row.Scan(&nickname); err != nil {
+		return answer, err
+	}
+
+	var tag pgx.CommandTag
+	var err error
+	if upTo != 0 {
+		tag, err = Notification.database.Exec("UPDATE notifications SET is_read = true WHERE u_nickname = $1 AND is_read = false AND n_id <= $2", nickname, upTo)
+	} else {
+		tag, err = Notification.database.Exec("UPDATE notifications SET is_read = true WHERE u_nickname = $1 AND is_read = false", nickname)
+	}
+
+	if err != nil {
+		return answer, err
+	}
+
+	answer.Marked = tag.RowsAffected()
+	row = Notification.database.QueryRow("SELECT COUNT(*) FROM notifications WHERE u_nickname = $1 AND is_read = false", nickname)
+	err = row.Scan(&answer.Unread)
+
+	return answer, err
+}
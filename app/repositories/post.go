@@ -1,13 +1,23 @@
 package repositories
 
 import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+	forumErrors "vk_db_project/app/errors"
 	"vk_db_project/app/models"
 )
 
 type IPostRepository interface {
-	GetPost(int, []string) (models.FullPost, error)
-	UpdatePost(post models.Post) (models.Post, error)
+	GetPost(int, []string, models.RelatedOptions) (models.FullPost, error)
+	GetPosts([]int64, []string) (map[int64]models.FullPost, error)
+	UpdatePost(post models.Post, expectedVersion int64) (models.Post, error)
+	SelectPostInfo(int64) (string, string, error)
+	DeletePost(int64) error
 }
 
 type PostRepoImpl struct {
@@ -18,7 +28,7 @@ func NewPostRepoImpl(db *pgx.ConnPool) PostRepoImpl {
 	return PostRepoImpl{dbLauncher: db}
 }
 
-func (PostRepo PostRepoImpl) GetPost(id int, flags []string) (models.FullPost, error) {
+func (PostRepo PostRepoImpl) GetPost(id int, flags []string, options models.RelatedOptions) (models.FullPost, error) {
 	msg := new(models.Post)
 	answer := models.FullPost{}
 
@@ -30,19 +40,26 @@ func (PostRepo PostRepoImpl) GetPost(id int, flags []string) (models.FullPost, e
 	}
 
 	var row *pgx.Row
-	tx.Prepare("get-msg", "SELECT m_id , date , message , edit , parent ,  u_nickname , t_id , f_slug FROM messages WHERE m_id = $1")
+	tx.Prepare("get-msg", "SELECT m_id , date , message , edit , parent ,  u_nickname , t_id , f_slug , version , path FROM messages WHERE m_id = $1")
 	if len(flags) == 0 {
 		row = tx.QueryRow("get-msg", id)
 	} else {
 		row = tx.QueryRow("get-msg", id)
 	}
-	err = row.Scan(&msg.Id, &msg.Created, &msg.Message, &msg.IsEdited, &msg.Parent, &msg.Author, &msg.Thread, &msg.Forum)
+	err = row.Scan(&msg.Id, &msg.Created, &msg.Message, &msg.IsEdited, &msg.Parent, &msg.Author, &msg.Thread, &msg.Forum, &msg.Version, &msg.Path)
 
 	if err != nil {
 		tx.Rollback()
 		return answer, err
 	}
 
+	mentioned := []models.Post{*msg}
+	if err = loadMentions(tx, mentioned); err != nil {
+		tx.Rollback()
+		return answer, err
+	}
+
+	msg.Mentions = mentioned[0].Mentions
 	answer.Post = msg
 	for _, value := range flags {
 		switch value {
@@ -63,36 +80,376 @@ func (PostRepo PostRepoImpl) GetPost(id int, flags []string) (models.FullPost, e
 
 		case "thread":
 			thread := new(models.Thread)
-			row = tx.QueryRow("SELECT t_id , date , message , title , votes , slug , u_nickname , f_slug FROM threads WHERE t_id = $1", msg.Thread)
+			row = tx.QueryRow("SELECT t_id , date , message , title , votes , slug , u_nickname , f_slug , version , tags FROM threads WHERE t_id = $1", msg.Thread)
 			var threadSlug *string
-			err = row.Scan(&thread.Id, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &threadSlug, &thread.Author, &thread.Forum)
+			err = row.Scan(&thread.Id, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &threadSlug, &thread.Author, &thread.Forum, &thread.Version, &thread.Tags)
 
 			if threadSlug != nil {
 				thread.Slug = *threadSlug
 			}
 
 			answer.Thread = thread
+
+		case "parent", "ancestors", "children", "context":
+			err = selectTreeRelated(tx, value, *msg, options, &answer)
+			if err != nil {
+				tx.Rollback()
+				return answer, err
+			}
 		}
 	}
 	tx.Commit()
 	return answer, nil
 }
 
-func (PostRepo PostRepoImpl) UpdatePost(updateData models.Post) (models.Post, error) {
+const postColumns = "m_id , date , message , edit , parent , u_nickname , t_id , f_slug , version"
 
-	var row *pgx.Row
-	if updateData.Message != "" {
-		row = PostRepo.dbLauncher.QueryRow("UPDATE messages SET edit = CASE WHEN message = $1 THEN FALSE ELSE TRUE END , message = $1  WHERE m_id = $2 RETURNING m_id , date , message , edit, parent , u_nickname , t_id, f_slug", updateData.Message, updateData.Id)
-	} else {
-		row = PostRepo.dbLauncher.QueryRow("SELECT m_id , date , message , edit, parent , u_nickname ,  t_id ,f_slug FROM messages WHERE m_id = $1", updateData.Id)
+// maxPathElement is greater than every post id, so path || maxPathElement is
+// an upper bound for the paths of a whole subtree.
+const maxPathElement = int64(math.MaxInt64)
+
+// selectTreeRelated fills one of the tree expansions of a post. All of them
+// are ranges over messages.path, so they run on the (t_id, path) index and
+// never walk the tree.
+func selectTreeRelated(tx *pgx.Tx, flag string, msg models.Post, options models.RelatedOptions, answer *models.FullPost) error {
+	path := pathIds(msg.Path)
+	if len(path) == 0 {
+		return nil
 	}
+	parentPath := path[:len(path)-1]
+
+	var err error
+	switch flag {
+	case "parent":
+		if len(parentPath) == 0 {
+			return nil
+		}
+		var parents []models.Post
+		parents, err = queryPosts(tx, "SELECT "+postColumns+" FROM messages WHERE t_id = $1 AND path = $2", msg.Thread, parentPath)
+		if len(parents) != 0 {
+			answer.Parent = &parents[0]
+		}
 
-	err := row.Scan(&updateData.Id, &updateData.Created, &updateData.Message, &updateData.IsEdited, &updateData.Parent, &updateData.Author, &updateData.Thread, &updateData.Forum)
+	case "ancestors":
+		answer.Ancestors, err = queryPosts(tx, "SELECT "+postColumns+" FROM messages WHERE t_id = $1 AND m_id = ANY($2) ORDER BY path", msg.Thread, parentPath)
+
+	case "children":
+		since := path
+		if options.ChildrenSince != 0 {
+			since = append(append([]int64{}, path...), options.ChildrenSince)
+		}
+		answer.Children, err = queryPosts(tx, "SELECT "+postColumns+" FROM messages WHERE t_id = $1 AND path > $2 AND path < $3 AND array_length(path, 1) = $4 ORDER BY path LIMIT $5",
+			msg.Thread, since, append(append([]int64{}, path...), maxPathElement), len(path)+1, options.ChildrenLimit)
+
+	case "context":
+		upper := append(append([]int64{}, parentPath...), maxPathElement)
+		context := &models.PostContext{}
+		context.Before, err = queryPosts(tx, "SELECT "+postColumns+" FROM messages WHERE t_id = $1 AND path > $2 AND path < $3 AND array_length(path, 1) = $4 ORDER BY path DESC LIMIT $5",
+			msg.Thread, parentPath, path, len(path), options.ContextSize)
+		if err != nil {
+			return err
+		}
+		for left, right := 0, len(context.Before)-1; left < right; left, right = left+1, right-1 {
+			context.Before[left], context.Before[right] = context.Before[right], context.Before[left]
+		}
+		context.After, err = queryPosts(tx, "SELECT "+postColumns+" FROM messages WHERE t_id = $1 AND path > $2 AND path < $3 AND array_length(path, 1) = $4 ORDER BY path LIMIT $5",
+			msg.Thread, path, upper, len(path), options.ContextSize)
+		answer.Context = context
+	}
+
+	return err
+}
+
+func queryPosts(tx *pgx.Tx, sql string, args ...interface{}) ([]models.Post, error) {
+	rows, err := tx.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]models.Post, 0)
+	for rows.Next() {
+		msg := models.Post{}
+		err = rows.Scan(&msg.Id, &msg.Created, &msg.Message, &msg.IsEdited, &msg.Parent, &msg.Author, &msg.Thread, &msg.Forum, &msg.Version)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		posts = append(posts, msg)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, loadMentions(tx, posts)
+}
+
+func pathIds(path pgtype.Int8Array) []int64 {
+	ids := make([]int64, 0, len(path.Elements))
+	for _, element := range path.Elements {
+		ids = append(ids, element.Int)
+	}
+
+	return ids
+}
+
+// GetPosts is GetPost for many ids at once, with the user, forum and thread
+// expansions only. Every table is read with a single = ANY($1) query, so
+// authors, forums and threads shared by several posts are fetched once. Ids
+// without a post are missing from the result.
+func (PostRepo PostRepoImpl) GetPosts(ids []int64, flags []string) (map[int64]models.FullPost, error) {
+	tx, err := PostRepo.dbLauncher.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT m_id , date , message , edit , parent , u_nickname , t_id , f_slug , version FROM messages WHERE m_id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]models.Post, 0, len(ids))
+	for rows.Next() {
+		msg := models.Post{}
+		err = rows.Scan(&msg.Id, &msg.Created, &msg.Message, &msg.IsEdited, &msg.Parent, &msg.Author, &msg.Thread, &msg.Forum, &msg.Version)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		posts = append(posts, msg)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = loadMentions(tx, posts); err != nil {
+		return nil, err
+	}
+
+	nicknames := make([]string, 0)
+	slugs := make([]string, 0)
+	threadIds := make([]int64, 0)
+	seen := make(map[string]bool)
+	for _, msg := range posts {
+		if key := "u:" + strings.ToLower(msg.Author); !seen[key] {
+			seen[key] = true
+			nicknames = append(nicknames, msg.Author)
+		}
+		if key := "f:" + strings.ToLower(msg.Forum); !seen[key] {
+			seen[key] = true
+			slugs = append(slugs, msg.Forum)
+		}
+		if key := "t:" + strconv.Itoa(msg.Thread); !seen[key] {
+			seen[key] = true
+			threadIds = append(threadIds, int64(msg.Thread))
+		}
+	}
+
+	authors := make(map[string]*models.UserModel)
+	forums := make(map[string]*models.Forum)
+	threads := make(map[int]*models.Thread)
+	for _, value := range flags {
+		switch value {
+		case "user":
+			err = selectRelated(tx, func(rows *pgx.Rows) error {
+				author := new(models.UserModel)
+				if err := rows.Scan(&author.Nickname, &author.Fullname, &author.Email, &author.About); err != nil {
+					return err
+				}
+				authors[strings.ToLower(author.Nickname)] = author
+				return nil
+			}, "SELECT nickname , fullname , email, about FROM users WHERE nickname = ANY($1::TEXT[]::CITEXT[])", nicknames)
+		case "forum":
+			err = selectRelated(tx, func(rows *pgx.Rows) error {
+				forum := new(models.Forum)
+				if err := rows.Scan(&forum.Slug, &forum.Title, &forum.User, &forum.Posts, &forum.Threads); err != nil {
+					return err
+				}
+				forums[strings.ToLower(forum.Slug)] = forum
+				return nil
+			}, "SELECT slug , title , u_nickname, message_counter , thread_counter FROM forums WHERE slug = ANY($1::TEXT[]::CITEXT[])", slugs)
+		case "thread":
+			err = selectRelated(tx, func(rows *pgx.Rows) error {
+				thread := new(models.Thread)
+				var threadSlug *string
+				if err := rows.Scan(&thread.Id, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &threadSlug, &thread.Author, &thread.Forum, &thread.Version, &thread.Tags); err != nil {
+					return err
+				}
+				if threadSlug != nil {
+					thread.Slug = *threadSlug
+				}
+				threads[thread.Id] = thread
+				return nil
+			}, "SELECT t_id , date , message , title , votes , slug , u_nickname , f_slug , version , tags FROM threads WHERE t_id = ANY($1)", threadIds)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	answer := make(map[int64]models.FullPost, len(posts))
+	for iter := range posts {
+		msg := &posts[iter]
+		answer[msg.Id] = models.FullPost{
+			Post:   msg,
+			Author: authors[strings.ToLower(msg.Author)],
+			Forum:  forums[strings.ToLower(msg.Forum)],
+			Thread: threads[msg.Thread],
+		}
+	}
+
+	return answer, tx.Commit()
+}
+
+// selectRelated runs one batch query of GetPosts and hands every row to scan.
+func selectRelated(tx *pgx.Tx, scan func(*pgx.Rows) error, sql string, arg interface{}) error {
+	rows, err := tx.Query(sql, arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// UpdatePost replaces the message of a post. Unless expectedVersion is
+// models.AnyVersion, the post must still be at that version.
+func (PostRepo PostRepoImpl) UpdatePost(updateData models.Post, expectedVersion int64) (models.Post, error) {
+
+	if updateData.Message == "" {
+		row := PostRepo.dbLauncher.QueryRow("SELECT m_id , date , message , edit, parent , u_nickname ,  t_id ,f_slug , version FROM messages WHERE m_id = $1", updateData.Id)
+		err := row.Scan(&updateData.Id, &updateData.Created, &updateData.Message, &updateData.IsEdited, &updateData.Parent, &updateData.Author, &updateData.Thread, &updateData.Forum, &updateData.Version)
+		if err != nil {
+			return updateData, err
+		}
+
+		if expectedVersion != models.AnyVersion && updateData.Version != expectedVersion {
+			return updateData, forumErrors.VersionMismatch
+		}
+
+		mentioned := []models.Post{updateData}
+		err = loadMentions(PostRepo.dbLauncher, mentioned)
+
+		return mentioned[0], err
+	}
+
+	candidates := updateData.Mentions
+
+	tx, err := PostRepo.dbLauncher.Begin()
+	if err != nil {
+		return updateData, err
+	}
+
+	oldMessage := ""
+	var oldVersion int64
+	row := tx.QueryRow("SELECT message , version FROM messages WHERE m_id = $1 FOR UPDATE", updateData.Id)
+	if err = row.Scan(&oldMessage, &oldVersion); err != nil {
+		tx.Rollback()
+		return updateData, err
+	}
+
+	if expectedVersion != models.AnyVersion && oldVersion != expectedVersion {
+		tx.Rollback()
+		return updateData, forumErrors.VersionMismatch
+	}
+
+	row = tx.QueryRow("UPDATE messages SET edit = CASE WHEN message = $1 THEN FALSE ELSE TRUE END , version = version + CASE WHEN message = $1 THEN 0 ELSE 1 END , message = $1  WHERE m_id = $2 RETURNING m_id , date , message , edit, parent , u_nickname , t_id, f_slug , version", updateData.Message, updateData.Id)
+
+	err = row.Scan(&updateData.Id, &updateData.Created, &updateData.Message, &updateData.IsEdited, &updateData.Parent, &updateData.Author, &updateData.Thread, &updateData.Forum, &updateData.Version)
 	if err != nil {
 		//fmt.Println("[DEBUG] error at method UpdatePost (updating new post with message field : "+updateData.Message[:15]+") :", err)
+		tx.Rollback()
 		return updateData, err
 	}
 
-	return updateData, nil
+	if oldMessage != updateData.Message {
+		err = syncMentions(tx, &updateData, candidates)
+		if err != nil {
+			tx.Rollback()
+			return updateData, err
+		}
+
+		err = enqueueWebhookEvents(tx, updateData.Forum, models.EventPostUpdated, updateData)
+		if err != nil {
+			tx.Rollback()
+			return updateData, err
+		}
+	} else {
+		updateData.Mentions = nil
+		mentioned := []models.Post{updateData}
+		if err = loadMentions(tx, mentioned); err != nil {
+			tx.Rollback()
+			return updateData, err
+		}
+		updateData = mentioned[0]
+	}
+
+	return updateData, tx.Commit()
+}
+
+// SelectPostInfo returns the forum and the author of a post.
+func (PostRepo PostRepoImpl) SelectPostInfo(id int64) (string, string, error) {
+	forumSlug := ""
+	author := ""
+
+	row := PostRepo.dbLauncher.QueryRow("SELECT f_slug , u_nickname FROM messages WHERE m_id = $1", id)
+	err := row.Scan(&forumSlug, &author)
+
+	return forumSlug, author, err
+}
+
+// DeletePost removes a post together with the replies under it and
+// everything pointing at them.
+func (PostRepo PostRepoImpl) DeletePost(id int64) error {
+	tx, err := PostRepo.dbLauncher.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = deletePost(tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// deletePost also takes the posts off the forum counter, the rollup and the
+// thread ranks.
+func deletePost(tx *pgx.Tx, id int64) error {
+	threadId := int64(0)
+	forumSlug := ""
+	row := tx.QueryRow("SELECT t_id , f_slug FROM messages WHERE m_id = $1 FOR UPDATE", id)
+	if err := row.Scan(&threadId, &forumSlug); err != nil {
+		return err
+	}
+
+	postIds := []int64{}
+	row = tx.QueryRow("SELECT array_agg(m_id) FROM messages WHERE t_id = $1 AND $2 = ANY(path)", threadId, id)
+	if err := row.Scan(&postIds); err != nil {
+		return err
+	}
+
+	if err := removePostContributions(tx, postIds); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("UPDATE forums SET message_counter = message_counter - $1 , version = version + 1 WHERE slug = $2", len(postIds), forumSlug)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM messages WHERE m_id = ANY($1)", postIds); err != nil {
+		return err
+	}
+
+	return touchThreadRank(tx, threadId, time.Time{})
+}
@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"vk_db_project/app/models"
+)
+
+// hot is stored as log2 of the sum of 2 ^ (t / rankHalfLife) over the posts
+// of a thread, plus 1 + votes for the thread at its creation time. Every term
+// decays at the same rate, so the order never changes with time alone and a
+// row only needs rescoring when its thread gets a post or a vote. activity is
+// the post part of the sum, NULL while there are no posts; rank_add in
+// db/db.sql adds two such logarithms.
+const rankHalfLife = 12 * time.Hour
+
+// rankSort is how one order reads threadRanks: the column to sort by, its
+// type for the since cursor, and the cursor text of a row.
+type rankSort struct {
+	column string
+	cast   string
+	cursor string
+}
+
+var rankSorts = map[string]rankSort{
+	models.SortHot:    {column: "R.hot", cast: "DOUBLE PRECISION", cursor: "R.hot::TEXT"},
+	models.SortTop:    {column: "R.votes", cast: "BIGINT", cursor: "R.votes::TEXT"},
+	models.SortActive: {column: "R.last_post", cast: "TIMESTAMP WITH TIME ZONE", cursor: "to_char(R.last_post AT TIME ZONE 'UTC', 'YYYY-MM-DD\"T\"HH24:MI:SS.US\"Z\"')"},
+}
+
+// postActivity sums the posts matching condition per thread the way hot
+// does; $1 is the half-life in seconds.
+func postActivity(condition string) string {
+	return "SELECT t_id , MAX(date) AS last_post , top + LN(SUM(POWER(2, x - top))) / LN(2) AS activity FROM (" +
+		"SELECT t_id , date , EXTRACT(EPOCH FROM date) / $1 AS x , MAX(EXTRACT(EPOCH FROM date) / $1) OVER (PARTITION BY t_id) AS top " +
+		"FROM messages WHERE " + condition + ") P GROUP BY t_id , top"
+}
+
+// rankBase is the thread and vote part of hot for the threadRanks columns
+// created and votes; $1 is the half-life in seconds.
+func rankBase(created string, votes string) string {
+	return "EXTRACT(EPOCH FROM " + created + ") / $1 + LN(1 + GREATEST(" + votes + ", 0)) / LN(2)"
+}
+
+// scoreThreadRanks computes the rows of the given threads from scratch, of
+// every thread when threadIds is nil. It is used for new threads and on
+// restore.
+func scoreThreadRanks(db executor, threadIds []int64) error {
+	postCondition, threadCondition := "TRUE", "TRUE"
+	values := []interface{}{rankHalfLife.Seconds()}
+	if threadIds != nil {
+		postCondition, threadCondition = "t_id = ANY($2)", "T.t_id = ANY($2)"
+		values = append(values, threadIds)
+	}
+
+	_, err := db.Exec("INSERT INTO threadRanks (t_id , f_slug , created , votes , last_post , activity , hot) "+
+		"SELECT T.t_id , T.f_slug , COALESCE(T.date, now()) , COALESCE(T.votes, 0) , COALESCE(P.last_post, T.date, now()) , P.activity , "+
+		"rank_add(P.activity , "+rankBase("COALESCE(T.date, now())", "COALESCE(T.votes, 0)")+") "+
+		"FROM threads T LEFT JOIN ("+postActivity(postCondition)+") P ON P.t_id = T.t_id WHERE "+threadCondition+" "+
+		"ON CONFLICT (t_id) DO UPDATE SET f_slug = EXCLUDED.f_slug , created = EXCLUDED.created , votes = EXCLUDED.votes , "+
+		"last_post = EXCLUDED.last_post , activity = EXCLUDED.activity , hot = EXCLUDED.hot , stale = 0", values...)
+
+	return err
+}
+
+// touchThreadRank is all that writing posts pays for ranking: last_post
+// moves for sort=active and the row is left to RefreshThreadRanks. A zero
+// lastPost only marks the row, for votes and deleted posts.
+func touchThreadRank(db executor, threadId int64, lastPost time.Time) error {
+	if lastPost.IsZero() {
+		_, err := db.Exec("UPDATE threadRanks SET stale = stale + 1 WHERE t_id = $1", threadId)
+		return err
+	}
+
+	_, err := db.Exec("UPDATE threadRanks SET last_post = GREATEST(last_post , $2) , stale = stale + 1 WHERE t_id = $1", threadId, lastPost)
+
+	return err
+}
+
+// RefreshThreadRanks rescores up to limit rows touched since their last run
+// and returns how many it did. Posts and the stale counter of a row are read
+// in one snapshot, and only that many touches are taken off, so a post that
+// commits meanwhile leaves its row stale for the next run.
+func (Forum ForumRepoImpl) RefreshThreadRanks(limit int) (int64, error) {
+	commandTag, err := Forum.database.Exec("WITH S AS (SELECT t_id , stale FROM threadRanks WHERE stale > 0 ORDER BY t_id LIMIT $2) "+
+		"UPDATE threadRanks R SET votes = COALESCE(T.votes, 0) , last_post = COALESCE(P.last_post, T.date, now()) , activity = P.activity , "+
+		"hot = rank_add(P.activity , "+rankBase("R.created", "COALESCE(T.votes, 0)")+") , stale = R.stale - S.stale "+
+		"FROM S JOIN threads T ON T.t_id = S.t_id LEFT JOIN ("+postActivity("t_id IN (SELECT t_id FROM S)")+") P ON P.t_id = S.t_id "+
+		"WHERE R.t_id = S.t_id", rankHalfLife.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+// GetRankedThreads pages through threadRanks, all forums when slug is
+// empty, keeping the threads that pass filter. since is the cursor returned
+// with the previous page: the sort value and the id of its last thread,
+// separated by a comma. days limits sort=top to threads created in the last
+// days days, zero means all time.
+func (Forum ForumRepoImpl) GetRankedThreads(slug string, sort string, days int, limit int, since string, filter models.ThreadFilter) ([]models.Thread, string, error) {
+	order, ok := rankSorts[sort]
+	if !ok {
+		order = rankSorts[models.SortHot]
+	}
+
+	if slug != "" {
+		row := Forum.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+		if err := row.Scan(&slug); err != nil {
+			return nil, "", err
+		}
+	}
+
+	selectQuery := "SELECT T.t_id , T.slug , T.u_nickname , T.f_slug , T.date , T.message , T.title , T.votes , T.version , COALESCE(T.locked, false) , T.tags , " + order.cursor + " " +
+		"FROM threadRanks R JOIN threads T ON T.t_id = R.t_id WHERE TRUE "
+	selectValues := []interface{}{}
+
+	if slug != "" {
+		forumStatus, forumValue, err := forumCondition(Forum.database, "R.f_slug", slug, filter.Recursive, len(selectValues)+1)
+		if err != nil {
+			return nil, "", err
+		}
+
+		selectValues = append(selectValues, forumValue)
+		selectQuery += "AND " + forumStatus + " "
+	}
+
+	if days != 0 && sort == models.SortTop {
+		selectValues = append(selectValues, days)
+		selectQuery += "AND R.created > now() - make_interval(days => $" + strconv.Itoa(len(selectValues)) + ") "
+	}
+
+	tagStatus, tagValues := tagCondition(filter, len(selectValues)+1)
+	selectQuery += tagStatus
+	selectValues = append(selectValues, tagValues...)
+
+	if separator := strings.LastIndex(since, ","); separator >= 0 {
+		selectValues = append(selectValues, since[:separator], since[separator+1:])
+		selectQuery += "AND (" + order.column + " , R.t_id) < ($" + strconv.Itoa(len(selectValues)-1) + "::TEXT::" + order.cast +
+			" , $" + strconv.Itoa(len(selectValues)) + "::TEXT::BIGINT) "
+	}
+
+	selectValues = append(selectValues, limit)
+	selectQuery += "ORDER BY " + order.column + " DESC , R.t_id DESC LIMIT $" + strconv.Itoa(len(selectValues))
+
+	rows, err := Forum.database.Query(selectQuery, selectValues...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	threads := make([]models.Thread, 0)
+	next := ""
+	for rows.Next() {
+		thread := models.Thread{}
+		var threadSlug *string
+		cursor := ""
+
+		err = rows.Scan(&thread.Id, &threadSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &thread.Version, &thread.Locked, &thread.Tags, &cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if threadSlug != nil {
+			thread.Slug = *threadSlug
+		}
+
+		threads = append(threads, thread)
+		next = cursor + "," + strconv.Itoa(thread.Id)
+	}
+
+	if len(threads) < limit {
+		next = ""
+	}
+
+	return threads, next, rows.Err()
+}
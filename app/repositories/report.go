@@ -0,0 +1,208 @@
+package repositories
+
+import (
+	"github.com/jackc/pgx"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+)
+
+type IReportRepository interface {
+	SelectTarget(string, int64) (models.Report, error)
+	CreateReport(models.Report) (models.Report, bool, error)
+	SelectOpenAuthor(string, string, int64) (string, error)
+	GetReports(string, string, int) ([]models.ReportGroup, error)
+	Resolve(string, models.Resolution, string) (models.ReportAction, error)
+}
+
+type ReportRepoImpl struct {
+	database *pgx.ConnPool
+}
+
+func NewReportRepoImpl(db *pgx.ConnPool) ReportRepoImpl {
+	return ReportRepoImpl{database: db}
+}
+
+// SelectTarget fills the forum, thread and author of the post or thread a
+// report is about.
+func (Report ReportRepoImpl) SelectTarget(kind string, target int64) (models.Report, error) {
+	report := models.Report{Type: kind, Target: target}
+
+	var row *pgx.Row
+	if kind == models.ReportPost {
+		row = Report.database.QueryRow("SELECT f_slug , t_id , u_nickname FROM messages WHERE m_id = $1", target)
+	} else {
+		row = Report.database.QueryRow("SELECT f_slug , t_id , u_nickname FROM threads WHERE t_id = $1", target)
+	}
+
+	err := row.Scan(&report.Forum, &report.Thread, &report.Author)
+
+	return report, err
+}
+
+// CreateReport reports the target of a report from SelectTarget. A reporter
+// has one open report per target, reporting it again replaces the category
+// and the text; the flag is false then.
+func (Report ReportRepoImpl) CreateReport(report models.Report) (models.Report, bool, error) {
+	row := Report.database.QueryRow("SELECT nickname FROM users WHERE nickname = $1", report.Reporter)
+	err := row.Scan(&report.Reporter)
+	if err == pgx.ErrNoRows {
+		return report, false, forumErrors.UserNotFound
+	}
+	if err != nil {
+		return report, false, err
+	}
+
+	created := false
+	row = Report.database.QueryRow("INSERT INTO reports (f_slug , kind , target , t_id , author , reporter , category , text) "+
+		"VALUES ($1 , $2 , $3 , $4 , NULLIF($5, '') , $6 , $7 , $8) "+
+		"ON CONFLICT (kind , target , reporter) WHERE status = 'open' DO UPDATE SET category = EXCLUDED.category , text = EXCLUDED.text , date = now() "+
+		"RETURNING r_id , status , date , xmax = 0",
+		report.Forum, report.Type, report.Target, report.Thread, report.Author, report.Reporter, report.Category, report.Text)
+	err = row.Scan(&report.Id, &report.Status, &report.Created, &created)
+
+	return report, created, err
+}
+
+// SelectOpenAuthor is pgx.ErrNoRows when the target has no open reports in
+// the forum.
+func (Report ReportRepoImpl) SelectOpenAuthor(slug string, kind string, target int64) (string, error) {
+	author := ""
+	row := Report.database.QueryRow("SELECT COALESCE(author, '') FROM reports WHERE f_slug = $1 AND kind = $2 AND target = $3 AND status = $4 LIMIT 1",
+		slug, kind, target, models.ReportOpen)
+	err := row.Scan(&author)
+
+	return author, err
+}
+
+// GetReports groups the reports of a forum by target, and resolved ones also
+// by the action that closed them. Open groups come by count, resolved ones
+// newest action first; open reports of deleted content are left out.
+func (Report ReportRepoImpl) GetReports(slug string, status string, limit int) ([]models.ReportGroup, error) {
+	row := Report.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err := row.Scan(&slug); err != nil {
+		return nil, err
+	}
+
+	order := "W.total DESC , W.last DESC"
+	if status == models.ReportResolved {
+		order = "W.action_id DESC"
+	}
+
+	rows, err := Report.database.Query("SELECT G.rank , G.r_id , G.kind , G.target , G.t_id , COALESCE(G.author, '') , G.reporter , G.category , G.text , G.date , G.last , "+
+		"COALESCE(A.a_id, 0) , COALESCE(A.action, '') , COALESCE(A.note, '') , COALESCE(A.moderator, '') , COALESCE(A.reports, 0) , COALESCE(A.date, G.date) "+
+		"FROM (SELECT W.* , dense_rank() OVER (ORDER BY "+order+" , W.kind , W.target , W.action_id) AS rank FROM ("+
+		"SELECT R.* , count(*) OVER P AS total , max(R.date) OVER P AS last FROM reports R "+
+		"WHERE R.f_slug = $1 AND R.status = $2 AND (R.status <> $4 OR CASE R.kind "+
+		"WHEN $5 THEN EXISTS (SELECT 1 FROM messages M WHERE M.m_id = R.target) "+
+		"ELSE EXISTS (SELECT 1 FROM threads T WHERE T.t_id = R.target) END) "+
+		"WINDOW P AS (PARTITION BY R.kind , R.target , R.action_id)) W) G "+
+		"LEFT JOIN reportActions A ON A.a_id = G.action_id "+
+		"WHERE G.rank <= $3 ORDER BY G.rank , G.date", slug, status, limit, models.ReportOpen, models.ReportPost)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]models.ReportGroup, 0)
+	lastRank := int64(0)
+	for rows.Next() {
+		rank := int64(0)
+		group := models.ReportGroup{}
+		report := models.Report{}
+		action := models.ReportAction{}
+		err = rows.Scan(&rank, &report.Id, &group.Type, &group.Target, &group.Thread, &group.Author, &report.Reporter, &report.Category, &report.Text, &report.Created, &group.LastReported,
+			&action.Id, &action.Action, &action.Note, &action.Moderator, &action.Reports, &action.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		if rank != lastRank {
+			group.Categories = map[string]int{}
+			group.Reports = make([]models.Report, 0)
+			if action.Id != 0 {
+				action.Type, action.Target, action.Author = group.Type, group.Target, group.Author
+				group.Action = &action
+			}
+
+			groups = append(groups, group)
+			lastRank = rank
+		}
+
+		current := &groups[len(groups)-1]
+		current.Count++
+		current.Categories[report.Category]++
+		current.Reports = append(current.Reports, report)
+	}
+
+	return groups, rows.Err()
+}
+
+// Resolve closes the open reports of a target and carries out the action in
+// the same transaction: the content is deleted, or its author banned with the
+// note as the reason. It is pgx.ErrNoRows when nothing is open.
+func (Report ReportRepoImpl) Resolve(slug string, resolution models.Resolution, moderator string) (models.ReportAction, error) {
+	answer := models.ReportAction{Type: resolution.Type, Target: resolution.Target, Action: resolution.Action, Note: resolution.Note, Moderator: moderator}
+
+	tx, err := Report.database.Begin()
+	if err != nil {
+		return answer, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err = row.Scan(&slug); err != nil {
+		return answer, err
+	}
+
+	rows, err := tx.Query("UPDATE reports SET status = $1 WHERE f_slug = $2 AND kind = $3 AND target = $4 AND status = $5 RETURNING r_id , COALESCE(author, '')",
+		models.ReportResolved, slug, resolution.Type, resolution.Target, models.ReportOpen)
+	if err != nil {
+		return answer, err
+	}
+
+	reportIds := make([]int64, 0)
+	for rows.Next() {
+		reportId := int64(0)
+		if err = rows.Scan(&reportId, &answer.Author); err != nil {
+			rows.Close()
+			return answer, err
+		}
+
+		reportIds = append(reportIds, reportId)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return answer, err
+	}
+
+	if len(reportIds) == 0 {
+		return answer, pgx.ErrNoRows
+	}
+
+	answer.Reports = len(reportIds)
+	row = tx.QueryRow("INSERT INTO reportActions (f_slug , kind , target , action , note , moderator , author , reports) "+
+		"VALUES ($1 , $2 , $3 , $4 , $5 , NULLIF($6, '') , NULLIF($7, '') , $8) RETURNING a_id , date",
+		slug, answer.Type, answer.Target, answer.Action, answer.Note, answer.Moderator, answer.Author, answer.Reports)
+	if err = row.Scan(&answer.Id, &answer.Created); err != nil {
+		return answer, err
+	}
+
+	if _, err = tx.Exec("UPDATE reports SET action_id = $1 WHERE r_id = ANY($2)", answer.Id, reportIds); err != nil {
+		return answer, err
+	}
+
+	switch {
+	case answer.Action == models.ResolveDelete && answer.Type == models.ReportPost:
+		err = deletePost(tx, answer.Target)
+	case answer.Action == models.ResolveDelete:
+		err = deleteThread(tx, int(answer.Target))
+	case answer.Action == models.ResolveBan && answer.Author != "":
+		_, err = tx.Exec(banUpsert, slug, answer.Author, moderator, answer.Note)
+	}
+	// The content may already be gone, the reports are closed all the same.
+	if err != nil && err != pgx.ErrNoRows {
+		return answer, err
+	}
+
+	return answer, tx.Commit()
+}
@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"github.com/jackc/pgx"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+)
+
+type IRoleRepository interface {
+	GetAccess(string, []string) (map[string]models.Access, error)
+	GetRoles(string) ([]models.ForumRole, error)
+	GrantRole(string, string, string, string) (models.ForumRole, error)
+	RevokeRole(string, string) error
+	GetBans(string) ([]models.ForumBan, error)
+	Ban(string, string, string, string) (models.ForumBan, error)
+	Unban(string, string) error
+}
+
+// banUpsert bans $2 in forum $1; banning again replaces the moderator and the
+// reason.
+const banUpsert = "INSERT INTO forumBans (f_slug , u_nickname , moderator , reason) VALUES ($1 , $2 , NULLIF($3, '') , $4) " +
+	"ON CONFLICT (f_slug , u_nickname) DO UPDATE SET moderator = EXCLUDED.moderator , reason = EXCLUDED.reason , date = now() "
+
+type RoleRepoImpl struct {
+	database *pgx.ConnPool
+}
+
+func NewRoleRepoImpl(db *pgx.ConnPool) RoleRepoImpl {
+	return RoleRepoImpl{database: db}
+}
+
+// selectMember resolves the slug of a forum and the nickname of a user to
+// the way they are stored, a missing user is forumErrors.UserNotFound.
+func (Role RoleRepoImpl) selectMember(slug string, nickname string) (string, string, error) {
+	row := Role.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err := row.Scan(&slug); err != nil {
+		return "", "", err
+	}
+
+	row = Role.database.QueryRow("SELECT nickname FROM users WHERE nickname = $1", nickname)
+	err := row.Scan(&nickname)
+	if err == pgx.ErrNoRows {
+		err = forumErrors.UserNotFound
+	}
+
+	return slug, nickname, err
+}
+
+// GetAccess answers with an entry for every nickname, keyed the way it was
+// asked for; users without a role or ban get the zero Access.
+func (Role RoleRepoImpl) GetAccess(slug string, nicknames []string) (map[string]models.Access, error) {
+	row := Role.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err := row.Scan(&slug); err != nil {
+		return nil, err
+	}
+
+	rows, err := Role.database.Query("SELECT N.nick , COALESCE(R.role, '') , "+
+		"EXISTS (SELECT 1 FROM forumBans B WHERE B.f_slug = $1 AND B.u_nickname = N.nick::CITEXT) "+
+		"FROM unnest($2::TEXT[]) AS N (nick) LEFT JOIN forumRoles R ON R.f_slug = $1 AND R.u_nickname = N.nick::CITEXT", slug, nicknames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	access := make(map[string]models.Access, len(nicknames))
+	for rows.Next() {
+		nickname := ""
+		entry := models.Access{}
+		if err = rows.Scan(&nickname, &entry.Role, &entry.Banned); err != nil {
+			return nil, err
+		}
+
+		access[nickname] = entry
+	}
+
+	return access, rows.Err()
+}
+
+// GetRoles lists the owner first, then moderators and members by nickname.
+func (Role RoleRepoImpl) GetRoles(slug string) ([]models.ForumRole, error) {
+	row := Role.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err := row.Scan(&slug); err != nil {
+		return nil, err
+	}
+
+	rows, err := Role.database.Query("SELECT u_nickname , role , COALESCE(granted_by, '') , date FROM forumRoles WHERE f_slug = $1 "+
+		"ORDER BY CASE role WHEN $2 THEN 0 WHEN $3 THEN 1 ELSE 2 END , u_nickname", slug, models.RoleOwner, models.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]models.ForumRole, 0)
+	for rows.Next() {
+		role := models.ForumRole{}
+		if err = rows.Scan(&role.Nickname, &role.Role, &role.GrantedBy, &role.Granted); err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// GrantRole sets the role of a user, replacing the one they had. The owner
+// keeps their role.
+func (Role RoleRepoImpl) GrantRole(slug string, nickname string, role string, grantedBy string) (models.ForumRole, error) {
+	answer := models.ForumRole{}
+
+	slug, nickname, err := Role.selectMember(slug, nickname)
+	if err != nil {
+		return answer, err
+	}
+
+	row := Role.database.QueryRow("INSERT INTO forumRoles (f_slug , u_nickname , role , granted_by) VALUES ($1 , $2 , $3 , NULLIF($4, '')) "+
+		"ON CONFLICT (f_slug , u_nickname) DO UPDATE SET role = EXCLUDED.role , granted_by = EXCLUDED.granted_by , date = now() "+
+		"WHERE forumRoles.role <> $5 "+
+		"RETURNING u_nickname , role , COALESCE(granted_by, '') , date", slug, nickname, role, grantedBy, models.RoleOwner)
+	err = row.Scan(&answer.Nickname, &answer.Role, &answer.GrantedBy, &answer.Granted)
+	if err == pgx.ErrNoRows {
+		err = forumErrors.OwnerRole
+	}
+
+	return answer, err
+}
+
+// RevokeRole is pgx.ErrNoRows when the user has no role to revoke.
+func (Role RoleRepoImpl) RevokeRole(slug string, nickname string) error {
+	slug, nickname, err := Role.selectMember(slug, nickname)
+	if err != nil {
+		return err
+	}
+
+	role := ""
+	row := Role.database.QueryRow("DELETE FROM forumRoles WHERE f_slug = $1 AND u_nickname = $2 AND role <> $3 RETURNING role", slug, nickname, models.RoleOwner)
+	err = row.Scan(&role)
+	if err != pgx.ErrNoRows {
+		return err
+	}
+
+	row = Role.database.QueryRow("SELECT role FROM forumRoles WHERE f_slug = $1 AND u_nickname = $2", slug, nickname)
+	if row.Scan(&role) == nil {
+		return forumErrors.OwnerRole
+	}
+
+	return pgx.ErrNoRows
+}
+
+func (Role RoleRepoImpl) GetBans(slug string) ([]models.ForumBan, error) {
+	row := Role.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err := row.Scan(&slug); err != nil {
+		return nil, err
+	}
+
+	rows, err := Role.database.Query("SELECT u_nickname , COALESCE(moderator, '') , reason , date FROM forumBans WHERE f_slug = $1 ORDER BY date DESC , u_nickname", slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := make([]models.ForumBan, 0)
+	for rows.Next() {
+		ban := models.ForumBan{}
+		if err = rows.Scan(&ban.Nickname, &ban.Moderator, &ban.Reason, &ban.Created); err != nil {
+			return nil, err
+		}
+
+		bans = append(bans, ban)
+	}
+
+	return bans, rows.Err()
+}
+
+// Ban is idempotent; banning again replaces the moderator and the reason.
+func (Role RoleRepoImpl) Ban(slug string, nickname string, moderator string, reason string) (models.ForumBan, error) {
+	answer := models.ForumBan{}
+
+	slug, nickname, err := Role.selectMember(slug, nickname)
+	if err != nil {
+		return answer, err
+	}
+
+	row := Role.database.QueryRow(banUpsert+"RETURNING u_nickname , COALESCE(moderator, '') , reason , date", slug, nickname, moderator, reason)
+	err = row.Scan(&answer.Nickname, &answer.Moderator, &answer.Reason, &answer.Created)
+
+	return answer, err
+}
+
+// Unban is pgx.ErrNoRows when the user is not banned.
+func (Role RoleRepoImpl) Unban(slug string, nickname string) error {
+	slug, nickname, err := Role.selectMember(slug, nickname)
+	if err != nil {
+		return err
+	}
+
+	tag, err := Role.database.Exec("DELETE FROM forumBans WHERE f_slug = $1 AND u_nickname = $2", slug, nickname)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"strconv"
+
+	"github.com/jackc/pgx"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+)
+
+// forumTreeLock serializes moves, so two of them can't close a cycle that
+// neither sees on its own.
+const forumTreeLock = 0x666f72756d
+
+const forumColumns = "slug , title , u_nickname , message_counter , thread_counter , version , COALESCE(parent, '') , total_messages , total_threads"
+
+func scanForum(row interface{ Scan(...interface{}) error }, forum *models.Forum) error {
+	return row.Scan(&forum.Slug, &forum.Title, &forum.User, &forum.Posts, &forum.Threads, &forum.Version, &forum.Parent, &forum.TotalPosts, &forum.TotalThreads)
+}
+
+// forumSubtree returns the slug of a forum followed by the slugs of all its
+// sub-forums, or pgx.ErrNoRows for a missing forum.
+func forumSubtree(db querier, slug string) ([]string, error) {
+	rows, err := db.Query("WITH RECURSIVE tree AS ("+
+		"SELECT slug FROM forums WHERE slug = $1 "+
+		"UNION SELECT F.slug FROM forums F JOIN tree T ON F.parent = T.slug"+
+		") SELECT slug::TEXT FROM tree ORDER BY slug = $1 DESC , slug", slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slugs := make([]string, 0)
+	for rows.Next() {
+		if err = rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+
+		slugs = append(slugs, slug)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(slugs) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return slugs, nil
+}
+
+// forumCondition matches column against a forum at placeholder $param, or
+// against the forum and all its sub-forums when recursive.
+func forumCondition(db querier, column string, slug string, recursive bool, param int) (string, interface{}, error) {
+	if !recursive {
+		return column + " = $" + strconv.Itoa(param), slug, nil
+	}
+
+	subtree, err := forumSubtree(db, slug)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return column + " = ANY($" + strconv.Itoa(param) + "::TEXT[]::CITEXT[])", subtree, nil
+}
+
+// rebuildForumTotals recomputes the totals of every forum from the restored
+// counters. The rollup trigger is off meanwhile, or every forum would pass
+// its new total on to its parent once more.
+func rebuildForumTotals(db executor) error {
+	if _, err := db.Exec("ALTER TABLE forums DISABLE TRIGGER u_forum_rollup"); err != nil {
+		return err
+	}
+
+	_, err := db.Exec("WITH RECURSIVE tree AS (" +
+		"SELECT slug AS root , slug FROM forums " +
+		"UNION SELECT T.root , F.slug FROM forums F JOIN tree T ON F.parent = T.slug" +
+		") UPDATE forums F SET total_messages = S.messages , total_threads = S.threads FROM (" +
+		"SELECT T.root , SUM(COALESCE(C.message_counter, 0)) AS messages , SUM(COALESCE(C.thread_counter, 0)) AS threads " +
+		"FROM tree T JOIN forums C ON C.slug = T.slug GROUP BY T.root" +
+		") S WHERE S.root = F.slug")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("ALTER TABLE forums ENABLE TRIGGER u_forum_rollup")
+
+	return err
+}
+
+func (Forum ForumRepoImpl) GetChildren(slug string) ([]models.Forum, error) {
+	row := Forum.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err := row.Scan(&slug); err != nil {
+		return nil, err
+	}
+
+	rows, err := Forum.database.Query("SELECT "+forumColumns+" FROM forums WHERE parent = $1 ORDER BY slug", slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := make([]models.Forum, 0)
+	for rows.Next() {
+		forum := models.Forum{}
+		if err = scanForum(rows, &forum); err != nil {
+			return nil, err
+		}
+
+		forums = append(forums, forum)
+	}
+
+	return forums, rows.Err()
+}
+
+// MoveForum puts a forum under parent, or makes it a top level forum for an
+// empty parent. The triggers in db.sql move its totals along.
+func (Forum ForumRepoImpl) MoveForum(slug string, parent string) (models.Forum, error) {
+	forum := models.Forum{}
+
+	tx, err := Forum.database.Begin()
+	if err != nil {
+		return forum, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("SELECT pg_advisory_xact_lock($1)", int64(forumTreeLock)); err != nil {
+		return forum, err
+	}
+
+	subtree, err := forumSubtree(tx, slug)
+	if err != nil {
+		return forum, err
+	}
+
+	if parent != "" {
+		row := tx.QueryRow("SELECT slug FROM forums WHERE slug = $1", parent)
+		if err = row.Scan(&parent); err != nil {
+			return forum, forumErrors.ParentNotFound
+		}
+
+		for _, descendant := range subtree {
+			if descendant == parent {
+				return forum, forumErrors.ForumCycle
+			}
+		}
+	}
+
+	row := tx.QueryRow("UPDATE forums SET version = version + CASE WHEN parent IS DISTINCT FROM NULLIF($2, '') THEN 1 ELSE 0 END , "+
+		"parent = NULLIF($2, '') WHERE slug = $1 RETURNING "+forumColumns, subtree[0], parent)
+	if err = scanForum(row, &forum); err != nil {
+		return forum, err
+	}
+
+	return forum, tx.Commit()
+}
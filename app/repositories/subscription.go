@@ -0,0 +1,228 @@
+package repositories
+
+import (
+	"strconv"
+
+	"github.com/jackc/pgx"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+)
+
+type ISubscriptionRepository interface {
+	Subscribe(string, string, int) (models.Subscription, error)
+	Unsubscribe(string, string, int) error
+	MarkSeen(string, string, int, int64) (models.Subscription, error)
+	GetSubscriptions(string, int, int64) ([]models.Subscription, error)
+	GetSettings(string) (models.SubscriptionSettings, error)
+	UpdateSettings(string, models.SubscriptionSettings) (models.SubscriptionSettings, error)
+}
+
+type SubscriptionRepoImpl struct {
+	database *pgx.ConnPool
+}
+
+func NewSubscriptionRepoImpl(db *pgx.ConnPool) SubscriptionRepoImpl {
+	return SubscriptionRepoImpl{database: db}
+}
+
+// notifySubscribers tells the subscribers of a thread about new posts in it.
+// Users that already got a reply, thread_post or mention notification for a
+// post are skipped, and nobody is notified about their own posts.
+func notifySubscribers(db executor, postIds []int64) error {
+	if len(postIds) == 0 {
+		return nil
+	}
+
+	_, err := db.Exec("INSERT INTO notifications (u_nickname , kind , actor , m_id , t_id , f_slug , date) "+
+		"SELECT S.u_nickname , $2 , M.u_nickname , M.m_id , M.t_id , M.f_slug , M.date FROM messages M JOIN subscriptions S ON S.t_id = M.t_id "+
+		"WHERE M.m_id = ANY($1) AND S.u_nickname <> M.u_nickname "+
+		"AND NOT EXISTS (SELECT 1 FROM notifications N WHERE N.u_nickname = S.u_nickname AND N.m_id = M.m_id) "+
+		"ORDER BY M.m_id", postIds, models.NotificationSubscription)
+
+	return err
+}
+
+// subscribePostAuthors counts posting as a visit: authors who already follow
+// the thread or have auto_subscribe on end up subscribed with their newest
+// post as the last seen one.
+func subscribePostAuthors(db executor, postIds []int64) error {
+	if len(postIds) == 0 {
+		return nil
+	}
+
+	_, err := db.Exec("INSERT INTO subscriptions (u_nickname , t_id , last_seen) "+
+		"SELECT M.u_nickname , M.t_id , MAX(M.m_id) FROM messages M JOIN users U ON U.nickname = M.u_nickname "+
+		"WHERE M.m_id = ANY($1) AND (U.auto_subscribe OR EXISTS (SELECT 1 FROM subscriptions S WHERE S.u_nickname = M.u_nickname AND S.t_id = M.t_id)) "+
+		"GROUP BY M.u_nickname , M.t_id "+
+		"ON CONFLICT (u_nickname , t_id) DO UPDATE SET last_seen = GREATEST(subscriptions.last_seen , EXCLUDED.last_seen)", postIds)
+
+	return err
+}
+
+func (Subscription SubscriptionRepoImpl) selectTarget(nickname string, slug string, id int) (string, int, error) {
+	row := Subscription.database.QueryRow("SELECT nickname FROM users WHERE nickname = $1", nickname)
+	if err := row.Scan(&nickname); err != nil {
+		return "", 0, err
+	}
+
+	if slug != "" {
+		row = Subscription.database.QueryRow("SELECT t_id FROM threads WHERE slug = $1", slug)
+	} else {
+		row = Subscription.database.QueryRow("SELECT t_id FROM threads WHERE t_id = $1", id)
+	}
+
+	err := row.Scan(&id)
+
+	return nickname, id, err
+}
+
+// Subscribe is idempotent; subscribing again marks the thread as seen.
+func (Subscription SubscriptionRepoImpl) Subscribe(nickname string, slug string, id int) (models.Subscription, error) {
+	answer := models.Subscription{}
+
+	nickname, threadId, err := Subscription.selectTarget(nickname, slug, id)
+	if err != nil {
+		return answer, err
+	}
+
+	row := Subscription.database.QueryRow("INSERT INTO subscriptions (u_nickname , t_id , last_seen) "+
+		"VALUES ($1 , $2 , (SELECT COALESCE(MAX(m_id), 0) FROM messages WHERE t_id = $2)) "+
+		"ON CONFLICT (u_nickname , t_id) DO UPDATE SET last_seen = EXCLUDED.last_seen "+
+		"RETURNING s_id , last_seen , date", nickname, threadId)
+	if err = row.Scan(&answer.Id, &answer.LastSeen, &answer.Created); err != nil {
+		return answer, err
+	}
+
+	threads, err := NewThreadRepoImpl(Subscription.database).GetThreadsByIds([]int64{int64(threadId)})
+	if err != nil {
+		return answer, err
+	}
+	if len(threads) == 0 {
+		return answer, pgx.ErrNoRows
+	}
+
+	answer.Nickname = nickname
+	answer.Thread = &threads[0]
+
+	return answer, nil
+}
+
+func (Subscription SubscriptionRepoImpl) Unsubscribe(nickname string, slug string, id int) error {
+	nickname, threadId, err := Subscription.selectTarget(nickname, slug, id)
+	if err != nil {
+		return err
+	}
+
+	tag, err := Subscription.database.Exec("DELETE FROM subscriptions WHERE u_nickname = $1 AND t_id = $2", nickname, threadId)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return forumErrors.NotSubscribed
+	}
+
+	return nil
+}
+
+// MarkSeen moves the last seen post of a subscription to lastSeen, or to the
+// newest post of the thread when it is zero. It never moves back, and is
+// forumErrors.NotSubscribed when the user does not follow the thread.
+func (Subscription SubscriptionRepoImpl) MarkSeen(nickname string, slug string, id int, lastSeen int64) (models.Subscription, error) {
+	answer := models.Subscription{}
+
+	nickname, threadId, err := Subscription.selectTarget(nickname, slug, id)
+	if err != nil {
+		return answer, err
+	}
+
+	row := Subscription.database.QueryRow("UPDATE subscriptions S SET last_seen = GREATEST(S.last_seen , "+
+		"COALESCE(NULLIF($3, 0), (SELECT MAX(m_id) FROM messages WHERE t_id = $2), 0)) WHERE S.u_nickname = $1 AND S.t_id = $2 "+
+		"RETURNING S.s_id , S.last_seen , S.date , (SELECT COUNT(*) FROM messages M WHERE M.t_id = $2 AND M.m_id > S.last_seen)", nickname, threadId, lastSeen)
+	err = row.Scan(&answer.Id, &answer.LastSeen, &answer.Created, &answer.NewPosts)
+	if err == pgx.ErrNoRows {
+		return answer, forumErrors.NotSubscribed
+	}
+	if err != nil {
+		return answer, err
+	}
+
+	threads, err := NewThreadRepoImpl(Subscription.database).GetThreadsByIds([]int64{int64(threadId)})
+	if err != nil {
+		return answer, err
+	}
+	if len(threads) == 0 {
+		return answer, pgx.ErrNoRows
+	}
+
+	answer.Nickname = nickname
+	answer.Thread = &threads[0]
+
+	return answer, nil
+}
+
+func (Subscription SubscriptionRepoImpl) GetSubscriptions(nickname string, limit int, since int64) ([]models.Subscription, error) {
+	row := Subscription.database.QueryRow("SELECT nickname FROM users WHERE nickname = $1", nickname)
+	if err := row.Scan(&nickname); err != nil {
+		return nil, err
+	}
+
+	selectQuery := "SELECT S.s_id , S.last_seen , S.date , (SELECT COUNT(*) FROM messages M WHERE M.t_id = S.t_id AND M.m_id > S.last_seen) , " +
+		"T.t_id , T.slug , T.u_nickname , T.f_slug , T.date , T.message , T.title , T.votes , T.version , COALESCE(T.locked, false) , T.tags " +
+		"FROM subscriptions S JOIN threads T ON T.t_id = S.t_id WHERE S.u_nickname = $1 "
+	selectValues := []interface{}{nickname}
+
+	if since != 0 {
+		selectValues = append(selectValues, since)
+		selectQuery += "AND S.s_id < $" + strconv.Itoa(len(selectValues)) + " "
+	}
+
+	selectQuery += "ORDER BY S.s_id DESC"
+	if limit != 0 {
+		selectValues = append(selectValues, limit)
+		selectQuery += " LIMIT $" + strconv.Itoa(len(selectValues))
+	}
+
+	rows, err := Subscription.database.Query(selectQuery, selectValues...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]models.Subscription, 0)
+	for rows.Next() {
+		subscription := models.Subscription{Nickname: nickname, Thread: new(models.Thread)}
+		thread := subscription.Thread
+		var threadSlug *string
+
+		err = rows.Scan(&subscription.Id, &subscription.LastSeen, &subscription.Created, &subscription.NewPosts,
+			&thread.Id, &threadSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &thread.Version, &thread.Locked, &thread.Tags)
+		if err != nil {
+			return nil, err
+		}
+
+		if threadSlug != nil {
+			thread.Slug = *threadSlug
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (Subscription SubscriptionRepoImpl) GetSettings(nickname string) (models.SubscriptionSettings, error) {
+	settings := models.SubscriptionSettings{}
+
+	row := Subscription.database.QueryRow("SELECT auto_subscribe FROM users WHERE nickname = $1", nickname)
+	err := row.Scan(&settings.AutoSubscribe)
+
+	return settings, err
+}
+
+func (Subscription SubscriptionRepoImpl) UpdateSettings(nickname string, settings models.SubscriptionSettings) (models.SubscriptionSettings, error) {
+	row := Subscription.database.QueryRow("UPDATE users SET auto_subscribe = $2 WHERE nickname = $1 RETURNING auto_subscribe", nickname, settings.AutoSubscribe)
+	err := row.Scan(&settings.AutoSubscribe)
+
+	return settings, err
+}
@@ -0,0 +1,253 @@
+package repositories
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"vk_db_project/app/models"
+)
+
+// testDatabase connects to the database in FORUM_TEST_DATABASE and loads
+// db/db.sql into it, dropping whatever was there. The tests are skipped
+// without one; never point it at a database you want to keep.
+func testDatabase(t *testing.T) *pgx.ConnPool {
+	connectString := os.Getenv("FORUM_TEST_DATABASE")
+	if connectString == "" {
+		t.Skip("FORUM_TEST_DATABASE is not set")
+	}
+
+	config, err := pgx.ParseConnectionString(connectString)
+	if err != nil {
+		t.Fatalf("FORUM_TEST_DATABASE: %v", err)
+	}
+
+	db, err := pgx.NewConnPool(pgx.ConnPoolConfig{ConnConfig: config, MaxConnections: 4})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	schema, err := ioutil.ReadFile("../../db/db.sql")
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	if _, err = db.Exec(string(schema)); err != nil {
+		t.Fatalf("load schema: %v", err)
+	}
+
+	return db
+}
+
+// newTestThread creates the users, forum "pirates" owned by "starter" and a
+// thread of "starter" in it.
+func newTestThread(t *testing.T, db *pgx.ConnPool, nicknames ...string) int {
+	users := NewUserRepoImpl(db)
+	for _, nickname := range append([]string{"starter"}, nicknames...) {
+		if _, err := users.CreateNewUser(models.UserModel{Nickname: nickname, Fullname: nickname, Email: nickname + "@example.com"}); err != nil {
+			t.Fatalf("create user %s: %v", nickname, err)
+		}
+	}
+
+	forums := NewForumRepoImpl(db)
+	if _, err := forums.CreateNewForum(models.Forum{Slug: "pirates", Title: "Pirates", User: "starter"}); err != nil {
+		t.Fatalf("create forum: %v", err)
+	}
+
+	thread, err := forums.CreateThread(models.Thread{Author: "starter", Forum: "pirates", Title: "Treasure", Message: "Where is it?"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+
+	return thread.Id
+}
+
+func createTestPost(t *testing.T, db *pgx.ConnPool, threadId int, post models.Post) int64 {
+	posts, err := NewThreadRepoImpl(db).CreatePost(time.Now(), "", threadId, []models.Post{post})
+	if err != nil {
+		t.Fatalf("create post of %s: %v", post.Author, err)
+	}
+
+	return posts[0].Id
+}
+
+func subscribeTest(t *testing.T, subscriptions SubscriptionRepoImpl, threadId int, nicknames ...string) {
+	for _, nickname := range nicknames {
+		if _, err := subscriptions.Subscribe(nickname, "", threadId); err != nil {
+			t.Fatalf("subscribe %s: %v", nickname, err)
+		}
+	}
+}
+
+// lastSeen is the last seen post of a subscription, or -1 without one.
+func lastSeen(t *testing.T, db *pgx.ConnPool, nickname string, threadId int) int64 {
+	seen := int64(0)
+	err := db.QueryRow("SELECT last_seen FROM subscriptions WHERE u_nickname = $1 AND t_id = $2", nickname, threadId).Scan(&seen)
+	if err == pgx.ErrNoRows {
+		return -1
+	}
+	if err != nil {
+		t.Fatalf("last seen of %s: %v", nickname, err)
+	}
+
+	return seen
+}
+
+// notificationKinds lists the kinds of the notifications a user got about a
+// post, sorted.
+func notificationKinds(t *testing.T, db *pgx.ConnPool, nickname string, postId int64) []string {
+	rows, err := db.Query("SELECT kind FROM notifications WHERE u_nickname = $1 AND m_id = $2", nickname, postId)
+	if err != nil {
+		t.Fatalf("notifications of %s: %v", nickname, err)
+	}
+	defer rows.Close()
+
+	kinds := make([]string, 0)
+	for rows.Next() {
+		kind := ""
+		if err = rows.Scan(&kind); err != nil {
+			t.Fatalf("notifications of %s: %v", nickname, err)
+		}
+		kinds = append(kinds, kind)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("notifications of %s: %v", nickname, err)
+	}
+
+	sort.Strings(kinds)
+	return kinds
+}
+
+func TestSubscribePostAuthors(t *testing.T) {
+	db := testDatabase(t)
+	threadId := newTestThread(t, db, "alice", "dave", "eve")
+	subscriptions := NewSubscriptionRepoImpl(db)
+
+	subscribeTest(t, subscriptions, threadId, "alice")
+	if _, err := subscriptions.UpdateSettings("dave", models.SubscriptionSettings{AutoSubscribe: true}); err != nil {
+		t.Fatalf("auto subscribe dave: %v", err)
+	}
+
+	first := createTestPost(t, db, threadId, models.Post{Author: "alice", Message: "ahoy"})
+	if seen := lastSeen(t, db, "alice", threadId); seen != first {
+		t.Errorf("subscriber posted %d, last seen is %d", first, seen)
+	}
+
+	second := createTestPost(t, db, threadId, models.Post{Author: "dave", Message: "arr"})
+	if seen := lastSeen(t, db, "dave", threadId); seen != second {
+		t.Errorf("auto_subscribe author posted %d, last seen is %d", second, seen)
+	}
+
+	createTestPost(t, db, threadId, models.Post{Author: "eve", Message: "hi"})
+	if seen := lastSeen(t, db, "eve", threadId); seen != -1 {
+		t.Errorf("author without auto_subscribe got subscribed, last seen %d", seen)
+	}
+	if seen := lastSeen(t, db, "alice", threadId); seen != first {
+		t.Errorf("posts of others moved the last seen post of alice to %d, want %d", seen, first)
+	}
+}
+
+func TestNotifySubscribersSkipsReplyAndMention(t *testing.T) {
+	db := testDatabase(t)
+	threadId := newTestThread(t, db, "alice", "bob", "carol")
+	subscribeTest(t, NewSubscriptionRepoImpl(db), threadId, "alice", "bob", "carol")
+
+	first := createTestPost(t, db, threadId, models.Post{Author: "alice", Message: "ahoy"})
+	reply := createTestPost(t, db, threadId, models.Post{Author: "bob", Message: "ahoy @carol", Parent: first, Mentions: []string{"carol"}})
+
+	tests := []struct {
+		nickname string
+		postId   int64
+		kinds    []string
+	}{
+		{"alice", first, []string{}},
+		{"bob", first, []string{models.NotificationSubscription}},
+		{"carol", first, []string{models.NotificationSubscription}},
+		{"starter", first, []string{models.NotificationThreadPost}},
+		{"alice", reply, []string{models.NotificationReply}},
+		{"bob", reply, []string{}},
+		{"carol", reply, []string{models.NotificationMention}},
+	}
+
+	for _, test := range tests {
+		kinds := notificationKinds(t, db, test.nickname, test.postId)
+		if len(kinds) != len(test.kinds) {
+			t.Errorf("%s about post %d: got %v, want %v", test.nickname, test.postId, kinds, test.kinds)
+			continue
+		}
+		for iter := range kinds {
+			if kinds[iter] != test.kinds[iter] {
+				t.Errorf("%s about post %d: got %v, want %v", test.nickname, test.postId, kinds, test.kinds)
+				break
+			}
+		}
+	}
+}
+
+func TestMarkSeenNeverMovesBack(t *testing.T) {
+	db := testDatabase(t)
+	threadId := newTestThread(t, db, "alice", "bob")
+	subscriptions := NewSubscriptionRepoImpl(db)
+	subscribeTest(t, subscriptions, threadId, "bob")
+
+	first := createTestPost(t, db, threadId, models.Post{Author: "alice", Message: "one"})
+	second := createTestPost(t, db, threadId, models.Post{Author: "alice", Message: "two"})
+	third := createTestPost(t, db, threadId, models.Post{Author: "alice", Message: "three"})
+
+	subscription, err := subscriptions.MarkSeen("bob", "", threadId, second)
+	if err != nil {
+		t.Fatalf("MarkSeen up to %d: %v", second, err)
+	}
+	if subscription.LastSeen != second || subscription.NewPosts != 1 {
+		t.Errorf("MarkSeen up to %d: last seen %d with %d new, want %d with 1 new", second, subscription.LastSeen, subscription.NewPosts, second)
+	}
+
+	subscription, err = subscriptions.MarkSeen("bob", "", threadId, first)
+	if err != nil {
+		t.Fatalf("MarkSeen back to %d: %v", first, err)
+	}
+	if subscription.LastSeen != second {
+		t.Errorf("MarkSeen back to %d moved the last seen post to %d, want it kept at %d", first, subscription.LastSeen, second)
+	}
+
+	subscription, err = subscriptions.MarkSeen("bob", "", threadId, 0)
+	if err != nil {
+		t.Fatalf("MarkSeen of the whole thread: %v", err)
+	}
+	if subscription.LastSeen != third || subscription.NewPosts != 0 {
+		t.Errorf("MarkSeen of the whole thread: last seen %d with %d new, want %d with none", subscription.LastSeen, subscription.NewPosts, third)
+	}
+}
+
+func TestGetSubscriptionsCountsNewPosts(t *testing.T) {
+	db := testDatabase(t)
+	threadId := newTestThread(t, db, "alice", "bob")
+	subscriptions := NewSubscriptionRepoImpl(db)
+
+	createTestPost(t, db, threadId, models.Post{Author: "alice", Message: "before"})
+	subscribeTest(t, subscriptions, threadId, "bob")
+
+	createTestPost(t, db, threadId, models.Post{Author: "alice", Message: "one"})
+	createTestPost(t, db, threadId, models.Post{Author: "alice", Message: "two"})
+
+	list, err := subscriptions.GetSubscriptions("bob", 0, 0)
+	if err != nil {
+		t.Fatalf("GetSubscriptions: %v", err)
+	}
+	if len(list) != 1 || list[0].Thread.Id != threadId || list[0].NewPosts != 2 {
+		t.Fatalf("GetSubscriptions: got %+v, want thread %d with 2 new posts", list, threadId)
+	}
+
+	createTestPost(t, db, threadId, models.Post{Author: "bob", Message: "mine"})
+
+	list, err = subscriptions.GetSubscriptions("bob", 0, 0)
+	if err != nil {
+		t.Fatalf("GetSubscriptions: %v", err)
+	}
+	if len(list) != 1 || list[0].NewPosts != 0 {
+		t.Fatalf("GetSubscriptions after posting: got %+v, want no new posts", list)
+	}
+}
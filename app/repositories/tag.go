@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"strconv"
+
+	"vk_db_project/app/models"
+)
+
+// tagCondition is the AND clause of filter on the threads aliased T, using
+// placeholder $param; it is empty, with no values, for an empty filter.
+func tagCondition(filter models.ThreadFilter, param int) (string, []interface{}) {
+	if len(filter.Tags) == 0 {
+		return "", nil
+	}
+
+	operator := "@>"
+	if filter.Mode == models.TagModeAny {
+		operator = "&&"
+	}
+
+	return "AND T.tags " + operator + " $" + strconv.Itoa(param) + "::TEXT[] ", []interface{}{filter.Tags}
+}
+
+// GetTags counts the threads of a forum per tag, most used tags first.
+func (Forum ForumRepoImpl) GetTags(slug string, limit int) ([]models.TagCount, error) {
+	row := Forum.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+	if err := row.Scan(&slug); err != nil {
+		return nil, err
+	}
+
+	rows, err := Forum.database.Query("SELECT tag , COUNT(*) FROM threads T , unnest(T.tags) AS tag "+
+		"WHERE T.f_slug = $1 GROUP BY tag ORDER BY 2 DESC , tag LIMIT $2", slug, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]models.TagCount, 0)
+	for rows.Next() {
+		tag := models.TagCount{}
+		if err = rows.Scan(&tag.Tag, &tag.Threads); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/pgtype"
+	forumErrors "vk_db_project/app/errors"
 	"vk_db_project/app/models"
 )
 
@@ -15,10 +17,14 @@ type IThreadRepository interface {
 	CreatePost(time.Time, string, int, []models.Post) ([]models.Post, error)
 	VoteThread(string, int, int, models.Thread) (models.Thread, error)
 	GetThread(int, models.Thread) (models.Thread, error)
+	GetThreadsByIds([]int64) ([]models.Thread, error)
 	GetPostsSorted(string, int, int, int, string, bool) ([]models.Post, error)
-	UpdateThread(string, int, models.Thread) (models.Thread, error)
+	UpdateThread(string, int, models.Thread, int64) (models.Thread, error)
 	GetParent(int, []models.Post) ([]models.Post, error)
 	SelectThreadInfo(string, int) (int, string, error)
+	ForEachPostTree(int, func(models.Post) error) error
+	LockThread(string, int, bool) (models.Thread, error)
+	DeleteThread(int) error
 }
 
 type ThreadRepoImpl struct {
@@ -38,22 +44,28 @@ func (Thread ThreadRepoImpl) CreatePost(timer time.Time, slug string, id int, po
 
 	threadId := 0
 	forumSlug := ""
+	locked := false
 	var rowslug *pgx.Row
 
 	if slug != "" {
-		rowslug = tx.QueryRow("SELECT t_id , f_slug FROM threads WHERE slug = $1", slug)
+		rowslug = tx.QueryRow("SELECT t_id , f_slug , COALESCE(locked, false) FROM threads WHERE slug = $1", slug)
 	} else {
-		rowslug = tx.QueryRow("SELECT t_id , f_slug FROM threads WHERE t_id = $1", id)
+		rowslug = tx.QueryRow("SELECT t_id , f_slug , COALESCE(locked, false) FROM threads WHERE t_id = $1", id)
 	}
 
-	err = rowslug.Scan(&threadId, &forumSlug)
+	err = rowslug.Scan(&threadId, &forumSlug, &locked)
 
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	_, err = tx.Prepare("insert-fu", "INSERT INTO forumUsers (f_slug,u_nickname) VALUES ($1,$2) ON CONFLICT (f_slug,u_nickname) DO NOTHING ")
+	if locked {
+		tx.Rollback()
+		return nil, forumErrors.ThreadLocked
+	}
+
+	_, err = tx.Prepare("insert-fu", "INSERT INTO forumUsers (f_slug,u_nickname,date) VALUES ($1,$2,$3) ON CONFLICT (f_slug,u_nickname) DO NOTHING ")
 	stmt, err := tx.Prepare("insert-post", "INSERT INTO messages (date , message , parent , path , u_nickname , f_slug , t_id) VALUES ($1 , $2 , $3 , $7::BIGINT[] , $4 , $5 , $6) RETURNING date , m_id")
 
 	if err != nil {
@@ -84,12 +96,80 @@ func (Thread ThreadRepoImpl) CreatePost(timer time.Time, slug string, id int, po
 			}
 			return nil, errors.New("no user")
 		}
+
+		if posts[iter].ImportedId != "" {
+			err = saveImported(tx, posts[iter].ImportedId, forumSlug, threadId, posts[iter].Id)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
 	}
 
-	tx.Exec("UPDATE forums SET message_counter = message_counter + $1 WHERE slug = $2", len(posts), forumSlug)
+	tx.Exec("UPDATE forums SET message_counter = message_counter + $1 , version = version + 1 WHERE slug = $2", len(posts), forumSlug)
+
+	for iter, _ := range posts {
+		tx.Exec("insert-fu", forumSlug, posts[iter].Author, timer)
+	}
+
+	postIds := make([]int64, 0, len(posts))
+	for iter, _ := range posts {
+		postIds = append(postIds, posts[iter].Id)
+	}
+
+	err = notifyPostRecipients(tx, postIds)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
+	// Mentions go after the replies, so a mentioned parent author keeps the
+	// reply notification only.
 	for iter, _ := range posts {
-		tx.Exec("insert-fu", forumSlug, posts[iter].Author)
+		if len(posts[iter].Mentions) != 0 {
+			err = syncMentions(tx, &posts[iter], posts[iter].Mentions)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+	}
+
+	err = notifySubscribers(tx, postIds)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = subscribePostAuthors(tx, postIds)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = addPostContributions(tx, postIds)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if len(posts) != 0 {
+		err = touchThreadRank(tx, int64(threadId), timer)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	payloads := make([]interface{}, 0, len(posts))
+	for iter, _ := range posts {
+		payloads = append(payloads, posts[iter])
+	}
+
+	err = enqueueWebhookEvents(tx, forumSlug, models.EventPostCreated, payloads...)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	tx.Commit()
@@ -166,13 +246,13 @@ func (Thread ThreadRepoImpl) VoteThread(nickname string, voice, threadId int, th
 	}()
 
 	if thread.Slug != "" {
-		row = tx.QueryRow("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes FROM threads WHERE slug = $1", thread.Slug)
+		row = tx.QueryRow("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes , tags FROM threads WHERE slug = $1", thread.Slug)
 	} else {
-		row = tx.QueryRow("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes FROM threads WHERE t_id = $1", threadId)
+		row = tx.QueryRow("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes , tags FROM threads WHERE t_id = $1", threadId)
 	}
 
 	var forumSlug *string
-	err = row.Scan(&thread.Id, &forumSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes)
+	err = row.Scan(&thread.Id, &forumSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &thread.Tags)
 
 	if forumSlug != nil {
 		thread.Slug = *forumSlug
@@ -184,8 +264,10 @@ func (Thread ThreadRepoImpl) VoteThread(nickname string, voice, threadId int, th
 	}
 
 	voted := 0
-	row = tx.QueryRow("SELECT counter , u_nickname FROM voteThreads WHERE t_id = $1 AND u_nickname = $2", thread.Id, nickname)
-	row.Scan(&voted, &voterNick)
+	voteChanged := false
+	var votedDate *time.Time
+	row = tx.QueryRow("SELECT counter , u_nickname , date FROM voteThreads WHERE t_id = $1 AND u_nickname = $2", thread.Id, nickname)
+	row.Scan(&voted, &voterNick, &votedDate)
 
 	if voice > 0 {
 		if voted != 1 {
@@ -196,7 +278,7 @@ func (Thread ThreadRepoImpl) VoteThread(nickname string, voice, threadId int, th
 				voteCounter = 1
 
 			} else {
-				_, err = tx.Exec("UPDATE voteThreads SET counter = $3 WHERE t_id = $1 AND u_nickname = $2", thread.Id, voterNick, 1)
+				_, err = tx.Exec("UPDATE voteThreads SET counter = $3 , date = now() WHERE t_id = $1 AND u_nickname = $2", thread.Id, voterNick, 1)
 				voteCounter = 2
 			}
 
@@ -207,6 +289,7 @@ func (Thread ThreadRepoImpl) VoteThread(nickname string, voice, threadId int, th
 
 			row = tx.QueryRow("UPDATE threads SET votes = votes + $2 WHERE t_id = $1 RETURNING votes", thread.Id, voteCounter)
 			err = row.Scan(&thread.Votes)
+			voteChanged = true
 
 		}
 	} else {
@@ -217,7 +300,7 @@ func (Thread ThreadRepoImpl) VoteThread(nickname string, voice, threadId int, th
 				voteCounter = 1
 
 			} else {
-				_, err = tx.Exec("UPDATE voteThreads SET counter = $3 WHERE t_id = $1 AND u_nickname = $2", thread.Id, voterNick, -1)
+				_, err = tx.Exec("UPDATE voteThreads SET counter = $3 , date = now() WHERE t_id = $1 AND u_nickname = $2", thread.Id, voterNick, -1)
 				voteCounter = 2
 			}
 
@@ -228,10 +311,31 @@ func (Thread ThreadRepoImpl) VoteThread(nickname string, voice, threadId int, th
 
 			row = tx.QueryRow("UPDATE threads SET votes = votes - $2 WHERE t_id = $1 RETURNING votes", thread.Id, voteCounter)
 			err = row.Scan(&thread.Votes)
+			voteChanged = true
 
 		}
 	}
 
+	if err == nil && voteChanged {
+		counter := 1
+		if voice <= 0 {
+			counter = -1
+		}
+		err = moveVoteContribution(tx, thread.Id, voted, votedDate, counter)
+	}
+
+	if err == nil && voteChanged {
+		err = touchThreadRank(tx, int64(thread.Id), time.Time{})
+	}
+
+	if err == nil && voteChanged {
+		err = enqueueWebhookEvents(tx, thread.Forum, models.EventThreadVoted, map[string]interface{}{
+			"thread":   thread,
+			"nickname": nickname,
+			"voice":    voice,
+		})
+	}
+
 	return thread, err
 
 }
@@ -241,13 +345,13 @@ func (Thread ThreadRepoImpl) GetThread(threadId int, thread models.Thread) (mode
 	var row *pgx.Row
 
 	if thread.Slug != "" {
-		row = Thread.dbLauncher.QueryRow("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes FROM threads WHERE slug = $1", thread.Slug)
+		row = Thread.dbLauncher.QueryRow("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes , version , COALESCE(locked, false) , tags FROM threads WHERE slug = $1", thread.Slug)
 	} else {
-		row = Thread.dbLauncher.QueryRow("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes FROM threads WHERE t_id = $1", threadId)
+		row = Thread.dbLauncher.QueryRow("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes , version , COALESCE(locked, false) , tags FROM threads WHERE t_id = $1", threadId)
 	}
 
 	var threadSlug *string
-	err := row.Scan(&thread.Id, &threadSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes)
+	err := row.Scan(&thread.Id, &threadSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &thread.Version, &thread.Locked, &thread.Tags)
 
 	if threadSlug != nil {
 		thread.Slug = *threadSlug
@@ -260,6 +364,31 @@ func (Thread ThreadRepoImpl) GetThread(threadId int, thread models.Thread) (mode
 	return thread, nil
 }
 
+func (Thread ThreadRepoImpl) GetThreadsByIds(ids []int64) ([]models.Thread, error) {
+	rows, err := Thread.dbLauncher.Query("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes , version , COALESCE(locked, false) , tags FROM threads WHERE t_id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := make([]models.Thread, 0, len(ids))
+	for rows.Next() {
+		thread := models.Thread{}
+		var threadSlug *string
+		err = rows.Scan(&thread.Id, &threadSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &thread.Version, &thread.Locked, &thread.Tags)
+		if err != nil {
+			return nil, err
+		}
+
+		if threadSlug != nil {
+			thread.Slug = *threadSlug
+		}
+		threads = append(threads, thread)
+	}
+
+	return threads, rows.Err()
+}
+
 func (Thread ThreadRepoImpl) GetPostsSorted(slug string, threadId int, limit int, since int, sortType string, desc bool) ([]models.Post, error) {
 
 	tx, err := Thread.dbLauncher.Begin()
@@ -276,7 +405,7 @@ func (Thread ThreadRepoImpl) GetPostsSorted(slug string, threadId int, limit int
 		ranger = "<"
 	}
 
-	selectQuery := "SELECT m_id , date , message , edit , parent , u_nickname , t_id , f_slug FROM "
+	selectQuery := "SELECT m_id , date , message , edit , parent , u_nickname , t_id , f_slug , version FROM "
 	whereQuery := " "
 	orderQuery := " ORDER BY m_id " + order + " "
 	limitQuery := " "
@@ -335,7 +464,7 @@ func (Thread ThreadRepoImpl) GetPostsSorted(slug string, threadId int, limit int
 
 	case "parent_tree":
 		sinceHitted := true
-		selectQuery = "SELECT M.m_id , M.date , M.message , M.edit , M.parent , M.u_nickname , M.t_id , M.f_slug FROM messages AS M "
+		selectQuery = "SELECT M.m_id , M.date , M.message , M.edit , M.parent , M.u_nickname , M.t_id , M.f_slug , M.version FROM messages AS M "
 		whereQuery = " WHERE M.t_id = $1 AND M.path[1] IN (SELECT m_id FROM messages WHERE t_id = $1 AND  parent = 0 "
 
 		if order != "DESC" {
@@ -377,7 +506,7 @@ func (Thread ThreadRepoImpl) GetPostsSorted(slug string, threadId int, limit int
 
 		for data.Next() {
 			msg := new(models.Post)
-			err = data.Scan(&msg.Id, &msg.Created, &msg.Message, &msg.IsEdited, &msg.Parent, &msg.Author, &msg.Thread, &msg.Forum)
+			err = data.Scan(&msg.Id, &msg.Created, &msg.Message, &msg.IsEdited, &msg.Parent, &msg.Author, &msg.Thread, &msg.Forum, &msg.Version)
 
 			if err != nil {
 				tx.Rollback()
@@ -389,6 +518,11 @@ func (Thread ThreadRepoImpl) GetPostsSorted(slug string, threadId int, limit int
 		data.Close()
 	}
 
+	if err = loadMentions(tx, messages); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	if len(messages) == 0 {
 		trow := tx.QueryRow("SELECT t_id , slug FROM threads WHERE t_id = $1", selectValues[0])
 
@@ -407,7 +541,56 @@ func (Thread ThreadRepoImpl) GetPostsSorted(slug string, threadId int, limit int
 
 }
 
-func (Thread ThreadRepoImpl) UpdateThread(slug string, threadId int, newThread models.Thread) (models.Thread, error) {
+// ForEachPostTree walks every post of the thread in the same path order as the
+// "tree" sort of GetPostsSorted. Rows are handed to visit as they arrive, so a
+// thread of any size is never held in memory at once.
+func (Thread ThreadRepoImpl) ForEachPostTree(threadId int, visit func(models.Post) error) error {
+	rows, err := Thread.dbLauncher.Query("SELECT m_id , date , message , edit , parent , u_nickname , t_id , f_slug , version , path FROM messages WHERE t_id = $1 ORDER BY path ASC", threadId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg := models.Post{}
+		err = rows.Scan(&msg.Id, &msg.Created, &msg.Message, &msg.IsEdited, &msg.Parent, &msg.Author, &msg.Thread, &msg.Forum, &msg.Version, &msg.Path)
+		if err != nil {
+			return err
+		}
+
+		if err = visit(msg); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (Thread ThreadRepoImpl) LockThread(slug string, threadId int, locked bool) (models.Thread, error) {
+	thread := models.Thread{}
+	var row *pgx.Row
+
+	if slug != "" {
+		row = Thread.dbLauncher.QueryRow("UPDATE threads SET version = version + CASE WHEN COALESCE(locked, false) = $2 THEN 0 ELSE 1 END , locked = $2 WHERE slug = $1 RETURNING t_id , slug , u_nickname , f_slug , date , message , title , votes , locked , version , tags", slug, locked)
+	} else {
+		row = Thread.dbLauncher.QueryRow("UPDATE threads SET version = version + CASE WHEN COALESCE(locked, false) = $2 THEN 0 ELSE 1 END , locked = $2 WHERE t_id = $1 RETURNING t_id , slug , u_nickname , f_slug , date , message , title , votes , locked , version , tags", threadId, locked)
+	}
+
+	var threadSlug *string
+	err := row.Scan(&thread.Id, &threadSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &thread.Locked, &thread.Version, &thread.Tags)
+
+	if threadSlug != nil {
+		thread.Slug = *threadSlug
+	}
+
+	return thread, err
+}
+
+// UpdateThread changes the title, message and tags of a thread; nil tags
+// are left as they are, an empty list removes them. Unless
+// expectedVersion is models.AnyVersion, the update only happens if the
+// thread is still at that version, otherwise VersionMismatch is returned.
+func (Thread ThreadRepoImpl) UpdateThread(slug string, threadId int, newThread models.Thread, expectedVersion int64) (models.Thread, error) {
 
 	whereCase := ""
 	queryValues := make([]interface{}, 0)
@@ -421,51 +604,134 @@ func (Thread ThreadRepoImpl) UpdateThread(slug string, threadId int, newThread m
 		queryValues = append(queryValues, threadId)
 	}
 
-	var err error
-	var threadRow *pgx.Rows
-	defer func() {
-		if threadRow != nil {
-			threadRow.Close()
-		}
-	}()
+	returningRow := " t_id , slug , u_nickname , f_slug , date , message , title , votes , version , COALESCE(locked, false) , tags "
 
-	if newThread.Title == "" && newThread.Message == "" {
-		threadRow, err = Thread.dbLauncher.Query("SELECT t_id , slug , u_nickname , f_slug , date , message , title , votes FROM threads "+whereCase, queryValues...)
+	if newThread.Title == "" && newThread.Message == "" && newThread.Tags == nil {
+		row := Thread.dbLauncher.QueryRow("SELECT"+returningRow+"FROM threads"+whereCase, queryValues...)
 
-		if err != nil || threadRow == nil {
-			return newThread, err
+		err := scanUpdatedThread(row, &newThread)
+		if err == nil && expectedVersion != models.AnyVersion && newThread.Version != expectedVersion {
+			return newThread, forumErrors.VersionMismatch
 		}
 
-		threadRow.Next()
-		err = threadRow.Scan(&newThread.Id, &newThread.Slug, &newThread.Author, &newThread.Forum, &newThread.Created, &newThread.Message, &newThread.Title, &newThread.Votes)
-		threadRow.Close()
-
 		return newThread, err
 	}
 
 	updateRow := "UPDATE threads SET "
-	returningRow := " RETURNING t_id , slug , u_nickname , f_slug , date , message , title , votes "
 	setRow := ""
+	changed := make([]string, 0, 3)
 
 	if newThread.Message != "" {
+		changed = append(changed, "message IS DISTINCT FROM $"+strconv.Itoa(queryOrder))
 		setRow += " message = $" + strconv.Itoa(queryOrder) + ","
 		queryValues = append(queryValues, newThread.Message)
 		queryOrder++
 	}
 
 	if newThread.Title != "" {
+		changed = append(changed, "title IS DISTINCT FROM $"+strconv.Itoa(queryOrder))
 		setRow += " title = $" + strconv.Itoa(queryOrder) + ","
 		queryValues = append(queryValues, newThread.Title)
 		queryOrder++
 	}
 
-	setRow = setRow[:len(setRow)-1]
-	newThreadRow := Thread.dbLauncher.QueryRow(updateRow+setRow+whereCase+returningRow, queryValues...)
+	if newThread.Tags != nil {
+		changed = append(changed, "tags IS DISTINCT FROM $"+strconv.Itoa(queryOrder)+"::TEXT[]")
+		setRow += " tags = $" + strconv.Itoa(queryOrder) + ","
+		queryValues = append(queryValues, newThread.Tags)
+		queryOrder++
+	}
+
+	setRow += " version = version + CASE WHEN " + strings.Join(changed, " OR ") + " THEN 1 ELSE 0 END"
+
+	versionCase := ""
+	if expectedVersion != models.AnyVersion {
+		versionCase = " AND version = $" + strconv.Itoa(queryOrder)
+		queryValues = append(queryValues, expectedVersion)
+	}
 
-	err = newThreadRow.Scan(&newThread.Id, &newThread.Slug, &newThread.Author, &newThread.Forum, &newThread.Created, &newThread.Message, &newThread.Title, &newThread.Votes)
+	newThreadRow := Thread.dbLauncher.QueryRow(updateRow+setRow+whereCase+versionCase+" RETURNING"+returningRow, queryValues...)
+
+	err := scanUpdatedThread(newThreadRow, &newThread)
+	if err == pgx.ErrNoRows && versionCase != "" {
+		// Tell a missing thread apart from one that was changed meanwhile.
+		exists := false
+		Thread.dbLauncher.QueryRow("SELECT EXISTS (SELECT 1 FROM threads"+whereCase+")", queryValues[0]).Scan(&exists)
+		if exists {
+			return newThread, forumErrors.VersionMismatch
+		}
+	}
+
+	return newThread, err
+}
+
+func scanUpdatedThread(row *pgx.Row, thread *models.Thread) error {
+	var threadSlug *string
+	err := row.Scan(&thread.Id, &threadSlug, &thread.Author, &thread.Forum, &thread.Created, &thread.Message, &thread.Title, &thread.Votes, &thread.Version, &thread.Locked, &thread.Tags)
+
+	if threadSlug != nil {
+		thread.Slug = *threadSlug
+	}
+
+	return err
+}
+
+// DeleteThread removes a thread with its posts, votes and everything pointing
+// at them.
+func (Thread ThreadRepoImpl) DeleteThread(threadId int) error {
+	tx, err := Thread.dbLauncher.Begin()
 	if err != nil {
-		return newThread, err
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = deleteThread(tx, threadId); err != nil {
+		return err
 	}
 
-	return newThread, nil
+	return tx.Commit()
+}
+
+// deleteThread also takes the thread off the forum counters and the rollup.
+// Like rebuildContributions, the thread comes off its creation day and every
+// vote off the day it was cast.
+func deleteThread(tx *pgx.Tx, threadId int) error {
+	forumSlug := ""
+	row := tx.QueryRow("SELECT f_slug FROM threads WHERE t_id = $1 FOR UPDATE", threadId)
+	if err := row.Scan(&forumSlug); err != nil {
+		return err
+	}
+
+	postIds := []int64{}
+	row = tx.QueryRow("SELECT COALESCE(array_agg(m_id), '{}') FROM messages WHERE t_id = $1", threadId)
+	if err := row.Scan(&postIds); err != nil {
+		return err
+	}
+
+	if err := removePostContributions(tx, postIds); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("UPDATE forumContributions C SET threads = C.threads - 1 FROM threads T "+
+		"WHERE T.t_id = $1 AND C.f_slug = T.f_slug AND C.day = T.date::DATE AND C.u_nickname = T.u_nickname", threadId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE forumContributions C SET votes = C.votes - V.votes FROM ("+
+		"SELECT T.f_slug , V.date::DATE AS day , T.u_nickname , SUM(V.counter) AS votes FROM voteThreads V JOIN threads T ON T.t_id = V.t_id "+
+		"WHERE V.t_id = $1 GROUP BY 1 , 2 , 3"+
+		") V WHERE C.f_slug = V.f_slug AND C.day = V.day AND C.u_nickname = V.u_nickname", threadId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE forums SET message_counter = message_counter - $1 , thread_counter = thread_counter - 1 , version = version + 1 WHERE slug = $2", len(postIds), forumSlug)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM threads WHERE t_id = $1", threadId)
+
+	return err
 }
@@ -12,6 +12,8 @@ type IUserRepo interface {
 	CreateNewUser(models.UserModel) ([]models.UserModel, error)
 	UpdateUserData(models.UserModel) (models.UserModel, error)
 	GetUserData(string) (models.UserModel, error)
+	GetUsersByNicknames([]string) ([]models.UserModel, error)
+	GetUserStats(string) (models.UserStats, error)
 	Status() models.Status
 	Clear()
 }
@@ -70,7 +72,7 @@ func (User UserRepoImpl) UpdateUserData(userModel models.UserModel) (models.User
 	values := make([]interface{}, 0)
 
 	querySting := "UPDATE users SET"
-	nickQuery := " WHERE nickname = $1 RETURNING u_id, nickname, fullname , email, about"
+	nickQuery := " WHERE nickname = $1 RETURNING u_id, nickname, fullname , email, about , version"
 	reqQuery := ""
 
 	values = append(values, userModel.Nickname)
@@ -93,21 +95,19 @@ func (User UserRepoImpl) UpdateUserData(userModel models.UserModel) (models.User
 		id++
 	}
 
-	if len(reqQuery) > 1 {
-		reqQuery = reqQuery[:len(reqQuery)-1]
-	}
+	reqQuery += " version = version + 1"
 
 	var row *pgx.Row
 
 	if len(values) == 1 {
-		row = User.database.QueryRow("SELECT u_id, nickname, fullname , email, about FROM users WHERE nickname = $1", values[0])
+		row = User.database.QueryRow("SELECT u_id, nickname, fullname , email, about , version FROM users WHERE nickname = $1", values[0])
 	} else {
 		row = User.database.QueryRow(querySting+reqQuery+nickQuery, values...)
 	}
 
 	userId := 0
 
-	err := row.Scan(&userId, &userModel.Nickname, &userModel.Fullname, &userModel.Email, &userModel.About)
+	err := row.Scan(&userId, &userModel.Nickname, &userModel.Fullname, &userModel.Email, &userModel.About, &userModel.Version)
 
 	return userModel, err
 
@@ -122,13 +122,44 @@ func (User UserRepoImpl) GetUserData(nickname string) (models.UserModel, error)
 		About:    "",
 	}
 
-	row := User.database.QueryRow("SELECT nickname , fullname , email, about FROM users WHERE nickname = $1", nickname)
+	row := User.database.QueryRow("SELECT nickname , fullname , email, about , version FROM users WHERE nickname = $1", nickname)
 
-	err := row.Scan(&userData.Nickname, &userData.Fullname, &userData.Email, &userData.About)
+	err := row.Scan(&userData.Nickname, &userData.Fullname, &userData.Email, &userData.About, &userData.Version)
 
 	return userData, err
 }
 
+// GetUserStats reads the counters the triggers maintain. A user who never
+// wrote anything has no userStats row yet and gets zeros.
+func (User UserRepoImpl) GetUserStats(nickname string) (models.UserStats, error) {
+	stats := models.UserStats{}
+
+	row := User.database.QueryRow("SELECT COALESCE(S.posts, 0) , COALESCE(S.threads, 0) , COALESCE(S.forums, 0) , COALESCE(S.votes, 0) , S.first_activity , S.last_activity "+
+		"FROM users U LEFT JOIN userStats S ON S.u_nickname = U.nickname WHERE U.nickname = $1", nickname)
+	err := row.Scan(&stats.Posts, &stats.Threads, &stats.Forums, &stats.Votes, &stats.FirstActivity, &stats.LastActivity)
+
+	return stats, err
+}
+
+func (User UserRepoImpl) GetUsersByNicknames(nicknames []string) ([]models.UserModel, error) {
+	rows, err := User.database.Query("SELECT nickname , fullname , email , about FROM users WHERE nickname = ANY($1::TEXT[]::CITEXT[])", nicknames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]models.UserModel, 0, len(nicknames))
+	for rows.Next() {
+		user := models.UserModel{}
+		if err = rows.Scan(&user.Nickname, &user.Fullname, &user.Email, &user.About); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
 func (User UserRepoImpl) Status() models.Status {
 
 	statAnswer := new(models.Status)
@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx"
+	"vk_db_project/app/models"
+)
+
+type IWebhookRepository interface {
+	CreateWebhook(models.Webhook) (models.Webhook, error)
+	GetWebhooks(string) ([]models.Webhook, error)
+	GetDeliveries(string, int64, int, int64) ([]models.WebhookDelivery, error)
+	ClaimEvents(int, time.Duration) ([]models.WebhookEvent, error)
+	SaveDelivery(models.WebhookEvent, models.WebhookDelivery, time.Time) error
+}
+
+type WebhookRepoImpl struct {
+	database *pgx.ConnPool
+}
+
+func NewWebhookRepoImpl(db *pgx.ConnPool) WebhookRepoImpl {
+	return WebhookRepoImpl{database: db}
+}
+
+// executor is satisfied by both *pgx.ConnPool and *pgx.Tx, so events can be
+// queued inside the transaction that produced them.
+type executor interface {
+	Exec(string, ...interface{}) (pgx.CommandTag, error)
+}
+
+// enqueueWebhookEvents writes one outbox row per payload for every webhook of
+// the forum that is subscribed to the event.
+func enqueueWebhookEvents(db executor, forum string, event string, payloads ...interface{}) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+
+	bodies := make([]string, 0, len(payloads))
+	for _, payload := range payloads {
+		body, err := json.Marshal(map[string]interface{}{"event": event, "forum": forum, "data": payload})
+		if err != nil {
+			return err
+		}
+		bodies = append(bodies, string(body))
+	}
+
+	_, err := db.Exec("INSERT INTO webhookOutbox (w_id , event , payload) "+
+		"SELECT W.w_id , $2 , P.body::jsonb FROM webhooks W , unnest($3::TEXT[]) WITH ORDINALITY AS P(body, n) "+
+		"WHERE W.f_slug = $1 AND $2 = ANY(W.events) ORDER BY P.n , W.w_id", forum, event, bodies)
+
+	return err
+}
+
+func (Webhook WebhookRepoImpl) CreateWebhook(webhook models.Webhook) (models.Webhook, error) {
+	row := Webhook.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", webhook.Forum)
+
+	err := row.Scan(&webhook.Forum)
+	if err != nil {
+		return webhook, err
+	}
+
+	row = Webhook.database.QueryRow("INSERT INTO webhooks (f_slug , url , secret , events) VALUES ($1 , $2 , $3 , $4) RETURNING w_id , date", webhook.Forum, webhook.Url, webhook.Secret, webhook.Events)
+	err = row.Scan(&webhook.Id, &webhook.Created)
+
+	return webhook, err
+}
+
+func (Webhook WebhookRepoImpl) GetWebhooks(slug string) ([]models.Webhook, error) {
+	forumSlug := ""
+	row := Webhook.database.QueryRow("SELECT slug FROM forums WHERE slug = $1", slug)
+
+	if err := row.Scan(&forumSlug); err != nil {
+		return nil, err
+	}
+
+	rows, err := Webhook.database.Query("SELECT w_id , f_slug , url , events , date FROM webhooks WHERE f_slug = $1 ORDER BY w_id", forumSlug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]models.Webhook, 0)
+	for rows.Next() {
+		webhook := models.Webhook{}
+		err = rows.Scan(&webhook.Id, &webhook.Forum, &webhook.Url, &webhook.Events, &webhook.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (Webhook WebhookRepoImpl) GetDeliveries(slug string, id int64, limit int, since int64) ([]models.WebhookDelivery, error) {
+	webhookId := int64(0)
+	row := Webhook.database.QueryRow("SELECT w_id FROM webhooks WHERE w_id = $1 AND f_slug = $2", id, slug)
+
+	if err := row.Scan(&webhookId); err != nil {
+		return nil, err
+	}
+
+	selectQuery := "SELECT D.d_id , D.w_id , O.event , D.o_id , D.attempt , D.status_code , D.error , D.duration_ms , O.status , D.date " +
+		"FROM webhookDeliveries D JOIN webhookOutbox O ON O.o_id = D.o_id WHERE D.w_id = $1 "
+	selectValues := []interface{}{webhookId}
+
+	if since != 0 {
+		selectValues = append(selectValues, since)
+		selectQuery += "AND D.d_id < $" + strconv.Itoa(len(selectValues)) + " "
+	}
+
+	selectQuery += "ORDER BY D.d_id DESC"
+	if limit != 0 {
+		selectValues = append(selectValues, limit)
+		selectQuery += " LIMIT $" + strconv.Itoa(len(selectValues))
+	}
+
+	rows, err := Webhook.database.Query(selectQuery, selectValues...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery := models.WebhookDelivery{}
+		err = rows.Scan(&delivery.Id, &delivery.Webhook, &delivery.Event, &delivery.EventId, &delivery.Attempt, &delivery.StatusCode,
+			&delivery.Error, &delivery.Duration, &delivery.Status, &delivery.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ClaimEvents picks due outbox rows and leases them to the caller by moving
+// next_attempt forward, so concurrent workers never send the same row twice.
+func (Webhook WebhookRepoImpl) ClaimEvents(limit int, lease time.Duration) ([]models.WebhookEvent, error) {
+	rows, err := Webhook.database.Query("UPDATE webhookOutbox O SET attempts = O.attempts + 1 , next_attempt = now() + $2::INTERVAL "+
+		"FROM webhooks W WHERE W.w_id = O.w_id AND O.o_id IN "+
+		"(SELECT o_id FROM webhookOutbox WHERE status = 'pending' AND next_attempt <= now() ORDER BY next_attempt LIMIT $1 FOR UPDATE SKIP LOCKED) "+
+		"RETURNING O.o_id , O.w_id , W.url , W.secret , O.event , O.payload::TEXT , O.attempts , O.date", limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.WebhookEvent, 0)
+	for rows.Next() {
+		event := models.WebhookEvent{}
+		payload := ""
+		err = rows.Scan(&event.Id, &event.Webhook, &event.Url, &event.Secret, &event.Event, &payload, &event.Attempts, &event.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// SaveDelivery logs one attempt and moves the outbox row to delivery.Status.
+// Pending rows are retried at nextAttempt.
+func (Webhook WebhookRepoImpl) SaveDelivery(event models.WebhookEvent, delivery models.WebhookDelivery, nextAttempt time.Time) error {
+	tx, err := Webhook.database.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO webhookDeliveries (o_id , w_id , attempt , status_code , error , duration_ms) VALUES ($1 , $2 , $3 , $4 , $5 , $6)",
+		event.Id, event.Webhook, delivery.Attempt, delivery.StatusCode, delivery.Error, delivery.Duration)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE webhookOutbox SET status = $2 , next_attempt = $3 WHERE o_id = $1", event.Id, delivery.Status, nextAttempt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
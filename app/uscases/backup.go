@@ -0,0 +1,115 @@
+package uscases
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+)
+
+type IBackupUsecase interface {
+	Backup(io.Writer) (map[string]int64, error)
+	Restore(io.Reader) (map[string]int64, error)
+}
+
+type BackupUsecaseImpl struct {
+	backupRepo repositories.IBackupRepository
+	userRepo   repositories.IUserRepo
+}
+
+func NewBackupUsecaseImpl(bRepo repositories.BackupRepoImpl, uRepo repositories.UserRepoImpl) BackupUsecaseImpl {
+	return BackupUsecaseImpl{backupRepo: bRepo, userRepo: uRepo}
+}
+
+func (BackupUC BackupUsecaseImpl) Backup(out io.Writer) (map[string]int64, error) {
+	archive := gzip.NewWriter(out)
+	encoder := json.NewEncoder(archive)
+
+	err := encoder.Encode(models.BackupHeader{Format: models.BackupFormat, Version: models.BackupVersion, Created: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := BackupUC.backupRepo.Dump(func(table string, row interface{}) error {
+		body, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+
+		return encoder.Encode(models.BackupLine{Table: table, Row: body})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = encoder.Encode(models.BackupLine{Counts: counts}); err != nil {
+		return nil, err
+	}
+
+	return counts, archive.Close()
+}
+
+// Restore loads a backup written by Backup and then checks the restored row
+// counts against both the trailer of the file and the server status.
+func (BackupUC BackupUsecaseImpl) Restore(in io.Reader) (map[string]int64, error) {
+	archive, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	decoder := json.NewDecoder(archive)
+
+	header := models.BackupHeader{}
+	if err = decoder.Decode(&header); err != nil {
+		return nil, err
+	}
+
+	if header.Format != models.BackupFormat || header.Version < 1 || header.Version > models.BackupVersion {
+		return nil, fmt.Errorf("unsupported backup %q version %d", header.Format, header.Version)
+	}
+
+	var expected map[string]int64
+	counts, err := BackupUC.backupRepo.Restore(func() (string, interface{}, error) {
+		line := models.BackupLine{}
+		if err := decoder.Decode(&line); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", nil, err
+		}
+
+		if line.Counts != nil {
+			expected = line.Counts
+			return "", nil, io.EOF
+		}
+
+		row := models.NewBackupRow(line.Table)
+		if row == nil {
+			return "", nil, fmt.Errorf("unknown table %q in backup", line.Table)
+		}
+
+		return line.Table, row, json.Unmarshal(line.Row, row)
+	}, extractMentions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range models.BackupTables {
+		if counts[table] != expected[table] {
+			return counts, fmt.Errorf("restored %d rows of %s, backup has %d", counts[table], table, expected[table])
+		}
+	}
+
+	status := BackupUC.userRepo.Status()
+	if int64(status.User) != counts["users"] || int64(status.Forum) != counts["forums"] ||
+		int64(status.Thread) != counts["threads"] || status.Post != counts["messages"] {
+		return counts, fmt.Errorf("status %+v does not match restored rows %v", status, counts)
+	}
+
+	return counts, nil
+}
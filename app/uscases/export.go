@@ -0,0 +1,191 @@
+package uscases
+
+import (
+	"bufio"
+	"encoding/json"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/markdown"
+	"vk_db_project/app/models"
+)
+
+const (
+	ExportJson     = "json"
+	ExportMarkdown = "markdown"
+	ExportHtml     = "html"
+)
+
+type exportedPost struct {
+	models.Post
+	Depth int `json:"depth"`
+}
+
+type threadExporter interface {
+	Begin(models.Thread) error
+	Post(exportedPost) error
+	End() error
+}
+
+// ExportThread streams the whole thread in tree order. open is called once
+// the thread is known to exist, so callers can still answer 404 or 400 before
+// anything is written.
+func (ThreadUC ThreadsUsecaseImpl) ExportThread(slugOrId string, format string, open func(models.Thread) io.Writer) error {
+	if format == "" {
+		format = ExportJson
+	}
+
+	if format != ExportJson && format != ExportMarkdown && format != ExportHtml {
+		return forumErrors.UnknownExportFormat
+	}
+
+	thread, err := ThreadUC.GetThread(slugOrId, FormatRaw)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriterSize(open(thread), 64*1024)
+
+	var exporter threadExporter
+	switch format {
+	case ExportJson:
+		exporter = &jsonExporter{writer: writer}
+	case ExportMarkdown:
+		exporter = &markdownExporter{writer: writer}
+	case ExportHtml:
+		exporter = &htmlExporter{writer: writer}
+	}
+
+	if err = exporter.Begin(thread); err != nil {
+		return err
+	}
+
+	err = ThreadUC.threadRepo.ForEachPostTree(thread.Id, func(post models.Post) error {
+		return exporter.Post(exportedPost{Post: post, Depth: len(post.Path.Elements)})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = exporter.End(); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+func ExportContentType(format string) (string, string) {
+	switch format {
+	case ExportMarkdown:
+		return "text/markdown; charset=utf-8", "md"
+	case ExportHtml:
+		return "text/html; charset=utf-8", "html"
+	}
+
+	return "application/json; charset=utf-8", "json"
+}
+
+type jsonExporter struct {
+	writer *bufio.Writer
+	posts  int
+}
+
+func (exporter *jsonExporter) Begin(thread models.Thread) error {
+	body, err := json.Marshal(thread)
+	if err != nil {
+		return err
+	}
+
+	exporter.writer.WriteString(`{"thread":`)
+	exporter.writer.Write(body)
+	_, err = exporter.writer.WriteString(`,"posts":[`)
+
+	return err
+}
+
+func (exporter *jsonExporter) Post(post exportedPost) error {
+	body, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+
+	if exporter.posts != 0 {
+		exporter.writer.WriteByte(',')
+	}
+	exporter.posts++
+
+	_, err = exporter.writer.Write(body)
+	return err
+}
+
+func (exporter *jsonExporter) End() error {
+	_, err := exporter.writer.WriteString("]}\n")
+	return err
+}
+
+type markdownExporter struct {
+	writer *bufio.Writer
+}
+
+func (exporter *markdownExporter) Begin(thread models.Thread) error {
+	_, err := exporter.writer.WriteString("# " + thread.Title + "\n\n" +
+		"_" + thread.Author + " in " + thread.Forum + ", " + thread.Created.Format(time.RFC3339) + "_\n\n" +
+		thread.Message + "\n\n---\n\n")
+
+	return err
+}
+
+// Post writes a reply as a list item indented by its depth, with the message
+// indented under it so the markdown nesting follows the tree.
+func (exporter *markdownExporter) Post(post exportedPost) error {
+	indent := strings.Repeat("    ", post.Depth-1)
+
+	exporter.writer.WriteString(indent + "- **" + post.Author + "** (" + post.Created.Format(time.RFC3339) + ", #" + strconv.FormatInt(post.Id, 10) + ")\n\n")
+	for _, line := range strings.Split(post.Message, "\n") {
+		exporter.writer.WriteString(indent + "    " + line + "\n")
+	}
+
+	_, err := exporter.writer.WriteString("\n")
+	return err
+}
+
+func (exporter *markdownExporter) End() error {
+	return nil
+}
+
+type htmlExporter struct {
+	writer *bufio.Writer
+}
+
+const exportStyle = `body{font-family:sans-serif;max-width:60em;margin:2em auto;padding:0 1em;color:#222}` +
+	`header{border-bottom:1px solid #ccc;margin-bottom:1em}` +
+	`.post{border-left:2px solid #ddd;padding:.2em .8em;margin:.6em 0}` +
+	`.meta{color:#666;font-size:.85em}pre{background:#f4f4f4;padding:.5em;overflow:auto}`
+
+func (exporter *htmlExporter) Begin(thread models.Thread) error {
+	_, err := exporter.writer.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>" + html.EscapeString(thread.Title) +
+		"</title><style>" + exportStyle + "</style></head><body>\n<header><h1>" + html.EscapeString(thread.Title) + "</h1>" +
+		"<p class=\"meta\">" + html.EscapeString(thread.Author) + " in " + html.EscapeString(thread.Forum) + ", " + thread.Created.Format(time.RFC3339) + "</p>" +
+		markdown.Render("thread", int64(thread.Id), thread.Version, thread.Message) + "</header>\n")
+
+	return err
+}
+
+func (exporter *htmlExporter) Post(post exportedPost) error {
+	margin := strconv.Itoa((post.Depth - 1) * 2)
+
+	_, err := exporter.writer.WriteString("<article class=\"post\" id=\"post-" + strconv.FormatInt(post.Id, 10) + "\" style=\"margin-left:" + margin + "em\">" +
+		"<p class=\"meta\">" + html.EscapeString(post.Author) + ", " + post.Created.Format(time.RFC3339) + "</p>" +
+		markdown.Render("post", post.Id, post.Version, post.Message) + "</article>\n")
+
+	return err
+}
+
+func (exporter *htmlExporter) End() error {
+	_, err := exporter.writer.WriteString("</body></html>\n")
+	return err
+}
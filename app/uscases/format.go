@@ -0,0 +1,39 @@
+package uscases
+
+import (
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/markdown"
+	"vk_db_project/app/models"
+)
+
+const (
+	FormatRaw  = "raw"
+	FormatHtml = "html"
+)
+
+func checkFormat(format string) error {
+	if format != "" && format != FormatRaw && format != FormatHtml {
+		return forumErrors.UnknownFormat
+	}
+
+	return nil
+}
+
+// renderPosts replaces post messages with their HTML rendering when asked to.
+func renderPosts(posts []models.Post, format string) {
+	if format != FormatHtml {
+		return
+	}
+
+	for iter, _ := range posts {
+		posts[iter].Message = markdown.Render("post", posts[iter].Id, posts[iter].Version, posts[iter].Message)
+	}
+}
+
+func renderThread(thread *models.Thread, format string) {
+	if format != FormatHtml || thread == nil {
+		return
+	}
+
+	thread.Message = markdown.Render("thread", int64(thread.Id), thread.Version, thread.Message)
+}
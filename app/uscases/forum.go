@@ -1,27 +1,47 @@
 package uscases
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+	forumErrors "vk_db_project/app/errors"
 	"vk_db_project/app/models"
 	"vk_db_project/app/repositories"
+	"vk_db_project/app/validation"
 )
 
 type IForumUsecase interface {
 	CreateForum(models.Forum) (models.Forum, error)
 	GetForumData(string) (models.Forum, error)
+	GetForums([]string) ([]models.Forum, error)
 	CreateThread(string, models.Thread) (models.Thread, error)
-	GetThreads(string, int, string, bool) ([]models.Thread, error)
+	GetThreads(string, int, string, bool, models.ThreadFilter) ([]models.Thread, error)
 	GetForumUsers(string, int, string, bool) ([]models.UserModel, error)
+	GetLeaderboard(string, string, string, int) ([]models.LeaderboardEntry, error)
+	GetRankedThreads(string, string, string, int, string, models.ThreadFilter) ([]models.Thread, string, error)
+	GetTags(string, int) ([]models.TagCount, error)
+	RunRankingWorker(time.Duration)
+	GetActivity(string, string, string, string) (models.ActivityStats, error)
+	GetChildren(string) ([]models.Forum, error)
+	MoveForum(string, string, string) (models.Forum, error)
+	ClearForum(string) error
 }
 
 type ForumUsecaseImpl struct {
-	ForumRepo repositories.IForumRepository
+	ForumRepo   repositories.IForumRepository
+	permissions IPermissionService
 }
 
-func NewForumUsecaseImpl(fRepo repositories.ForumRepoImpl) ForumUsecaseImpl {
-	return ForumUsecaseImpl{ForumRepo: fRepo}
+func NewForumUsecaseImpl(fRepo repositories.ForumRepoImpl, permissions PermissionServiceImpl) ForumUsecaseImpl {
+	return ForumUsecaseImpl{ForumRepo: fRepo, permissions: permissions}
 }
 
 func (ForumUC ForumUsecaseImpl) CreateForum(forum models.Forum) (models.Forum, error) {
+	if err := validation.Forum(forum); err != nil {
+		return forum, err
+	}
+
 	return ForumUC.ForumRepo.CreateNewForum(forum)
 }
 
@@ -29,17 +49,214 @@ func (ForumUC ForumUsecaseImpl) GetForumData(slug string) (models.Forum, error)
 	return ForumUC.ForumRepo.GetForum(slug)
 }
 
+func (ForumUC ForumUsecaseImpl) GetForums(slugs []string) ([]models.Forum, error) {
+	return ForumUC.ForumRepo.GetForumsBySlugs(slugs)
+}
+
 func (ForumUC ForumUsecaseImpl) CreateThread(slug string, thread models.Thread) (models.Thread, error) {
 	thread.Forum = slug
+	thread.Tags = normalizeTags(thread.Tags)
+	if err := validation.Thread(thread); err != nil {
+		return thread, err
+	}
+
+	if err := ForumUC.permissions.AuthorizeAuthors(slug, []string{thread.Author}); err != nil {
+		return thread, err
+	}
+
 	return ForumUC.ForumRepo.CreateThread(thread)
 }
 
-func (ForumUC ForumUsecaseImpl) GetThreads(slug string, limit int, since string, sort bool) ([]models.Thread, error) {
+func (ForumUC ForumUsecaseImpl) GetThreads(slug string, limit int, since string, sort bool, filter models.ThreadFilter) ([]models.Thread, error) {
+	filter, err := checkThreadFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
 	forum := models.Forum{Slug: slug}
 
-	return ForumUC.ForumRepo.GetThreads(forum, limit, since, sort)
+	return ForumUC.ForumRepo.GetThreads(forum, limit, since, sort, filter)
+}
+
+// checkThreadFilter normalizes the tags of filter like those of threads and
+// defaults to threads having all of them.
+func checkThreadFilter(filter models.ThreadFilter) (models.ThreadFilter, error) {
+	filter.Tags = normalizeTags(filter.Tags)
+	if filter.Mode == "" {
+		filter.Mode = models.TagModeAll
+	}
+
+	return filter, validation.ThreadFilter(filter)
+}
+
+const (
+	DefaultTagsLimit = 100
+	MaxTagsLimit     = 1000
+)
+
+func (ForumUC ForumUsecaseImpl) GetTags(slug string, limit int) ([]models.TagCount, error) {
+	if limit <= 0 {
+		limit = DefaultTagsLimit
+	}
+	if limit > MaxTagsLimit {
+		limit = MaxTagsLimit
+	}
+
+	return ForumUC.ForumRepo.GetTags(slug, limit)
 }
 
 func (ForumUC ForumUsecaseImpl) GetForumUsers(slug string, limit int, since string, desc bool) ([]models.UserModel, error) {
 	return ForumUC.ForumRepo.GetForumUsers(slug, limit, since, desc)
-}
\ No newline at end of file
+}
+
+const (
+	DefaultLeaderboardLimit = 10
+	MaxLeaderboardLimit     = 100
+)
+
+// periodDays is how many days, today included, a period covers.
+var periodDays = map[string]int{
+	models.PeriodDay:   1,
+	models.PeriodWeek:  7,
+	models.PeriodMonth: 30,
+	models.PeriodAll:   0,
+}
+
+// GetLeaderboard defaults to the all-time post leaderboard.
+func (ForumUC ForumUsecaseImpl) GetLeaderboard(slug string, metric string, period string, limit int) ([]models.LeaderboardEntry, error) {
+	if metric == "" {
+		metric = models.MetricPosts
+	}
+	if period == "" {
+		period = models.PeriodAll
+	}
+	if err := validation.Leaderboard(metric, period); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultLeaderboardLimit
+	}
+	if limit > MaxLeaderboardLimit {
+		limit = MaxLeaderboardLimit
+	}
+
+	return ForumUC.ForumRepo.GetLeaderboard(slug, metric, periodDays[period], limit)
+}
+
+const (
+	DefaultRankedLimit = 20
+	MaxRankedLimit     = 100
+)
+
+// GetRankedThreads lists the threads of a forum, or of all forums when slug
+// is empty, in a precomputed order. It also returns the since cursor of the
+// next page, empty after the last one.
+func (ForumUC ForumUsecaseImpl) GetRankedThreads(slug string, sort string, period string, limit int, since string, filter models.ThreadFilter) ([]models.Thread, string, error) {
+	if sort == "" {
+		sort = models.SortHot
+	}
+	if period == "" {
+		period = models.PeriodAll
+	}
+	if err := validation.RankedThreads(sort, period, since); err != nil {
+		return nil, "", err
+	}
+	filter, err := checkThreadFilter(filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if limit <= 0 {
+		limit = DefaultRankedLimit
+	}
+	if limit > MaxRankedLimit {
+		limit = MaxRankedLimit
+	}
+
+	return ForumUC.ForumRepo.GetRankedThreads(slug, sort, periodDays[period], limit, since, filter)
+}
+
+// rankBatchSize is how many threads one RefreshThreadRanks call rescores.
+const rankBatchSize = 1000
+
+// RunRankingWorker rescores the threads that got posts or votes, a batch at
+// a time, and sleeps interval once it has caught up. Only touched threads
+// cost anything, since hot does not decay in place.
+func (ForumUC ForumUsecaseImpl) RunRankingWorker(interval time.Duration) {
+	for {
+		rescored, err := ForumUC.ForumRepo.RefreshThreadRanks(rankBatchSize)
+		if err != nil {
+			fmt.Println("ranking: refresh thread ranks:", err)
+		}
+
+		if rescored < rankBatchSize {
+			time.Sleep(interval)
+		}
+	}
+}
+
+// DefaultBuckets is how many buckets back from to the window starts when
+// from is left out.
+const DefaultBuckets = 30
+
+// GetActivity counts threads, posts, joined users and votes of a forum, or
+// of all forums when slug is empty, per bucket. to defaults to now and the
+// bucket to a day.
+func (ForumUC ForumUsecaseImpl) GetActivity(slug string, from string, to string, bucket string) (models.ActivityStats, error) {
+	if bucket == "" {
+		bucket = models.BucketDay
+	}
+	if to == "" {
+		to = time.Now().UTC().Truncate(time.Second).Format(time.RFC3339Nano)
+	}
+	if end, err := time.Parse(time.RFC3339Nano, to); err == nil && from == "" {
+		from = end.Add(-DefaultBuckets * models.BucketSizes[bucket]).Format(time.RFC3339Nano)
+	}
+	if err := validation.Activity(from, to, bucket); err != nil {
+		return models.ActivityStats{}, err
+	}
+
+	stats := models.ActivityStats{Forum: slug, Bucket: bucket, Buckets: make([]models.ActivityBucket, 0)}
+	stats.From, _ = time.Parse(time.RFC3339Nano, from)
+	stats.To, _ = time.Parse(time.RFC3339Nano, to)
+	stats.From, stats.To = stats.From.UTC(), stats.To.UTC()
+
+	size := models.BucketSizes[bucket]
+	for start := stats.From.Truncate(size); start.Before(stats.To); start = start.Add(size) {
+		stats.Buckets = append(stats.Buckets, models.ActivityBucket{Start: start})
+	}
+
+	return ForumUC.ForumRepo.GetActivity(stats)
+}
+
+func (ForumUC ForumUsecaseImpl) GetChildren(slug string) ([]models.Forum, error) {
+	return ForumUC.ForumRepo.GetChildren(slug)
+}
+
+// MoveForum takes the owner of the forum, who also has to own the new parent.
+func (ForumUC ForumUsecaseImpl) MoveForum(slug string, actor string, parent string) (models.Forum, error) {
+	if err := validation.ForumMove(parent); err != nil {
+		return models.Forum{}, err
+	}
+
+	if err := ForumUC.permissions.Authorize(actor, models.ActionManage, slug, ""); err != nil {
+		return models.Forum{}, err
+	}
+
+	if parent != "" {
+		err := ForumUC.permissions.Authorize(actor, models.ActionManage, parent, "")
+		if err == pgx.ErrNoRows {
+			err = forumErrors.ParentNotFound
+		}
+		if err != nil {
+			return models.Forum{}, err
+		}
+	}
+
+	return ForumUC.ForumRepo.MoveForum(slug, parent)
+}
+
+func (ForumUC ForumUsecaseImpl) ClearForum(slug string) error {
+	return ForumUC.ForumRepo.ClearForum(slug)
+}
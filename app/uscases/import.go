@@ -0,0 +1,287 @@
+package uscases
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"vk_db_project/app/mbox"
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+)
+
+var nicknameStrip = regexp.MustCompile(`[^A-Za-z0-9_.]`)
+
+type IImportUsecase interface {
+	ImportMbox(string, io.Reader) (models.ImportResult, error)
+}
+
+type ImportUsecaseImpl struct {
+	importRepo repositories.IImportRepository
+	userRepo   repositories.IUserRepo
+	forumRepo  repositories.IForumRepository
+	threadRepo repositories.IThreadRepository
+}
+
+func NewImportUsecaseImpl(iRepo repositories.ImportRepoImpl, uRepo repositories.UserRepoImpl, fRepo repositories.ForumRepoImpl, tRepo repositories.ThreadRepoImpl) ImportUsecaseImpl {
+	return ImportUsecaseImpl{importRepo: iRepo, userRepo: uRepo, forumRepo: fRepo, threadRepo: tRepo}
+}
+
+type importedMessage struct {
+	threadId int
+	postId   int64
+}
+
+// ImportMbox turns top-level messages of the mailbox into threads and replies
+// into posts whose parent follows In-Reply-To/References. Message-IDs that
+// were imported into the forum before are skipped, so running it twice is
+// harmless. Mails without a date are rejected; their replies attach to the
+// nearest ancestor that was imported.
+func (ImportUC ImportUsecaseImpl) ImportMbox(slug string, in io.Reader) (models.ImportResult, error) {
+	result := models.ImportResult{}
+
+	forum, err := ImportUC.forumRepo.GetForum(slug)
+	if err != nil {
+		return result, err
+	}
+
+	messages, err := readMailbox(in)
+	if err != nil {
+		return result, err
+	}
+
+	imported := make(map[string]importedMessage)
+	authors := make(map[string]string)
+	for _, message := range ImportUC.replyOrder(messages) {
+		if _, ok := imported[message.Id]; ok {
+			result.Skipped++
+			continue
+		}
+
+		if message.Date.IsZero() {
+			result.Rejected++
+			continue
+		}
+
+		threadId, postId, err := ImportUC.importRepo.GetImported(forum.Slug, message.Id)
+		if err == nil {
+			imported[message.Id] = importedMessage{threadId: threadId, postId: postId}
+			result.Skipped++
+			continue
+		}
+
+		if err != pgx.ErrNoRows {
+			return result, err
+		}
+
+		author, created, err := ImportUC.ensureAuthor(message, authors)
+		if err != nil {
+			return result, err
+		}
+		if created {
+			result.Users++
+		}
+
+		parent, hasParent := ImportUC.findParent(forum.Slug, message, imported)
+		if !hasParent {
+			title := message.Subject
+			if title == "" {
+				title = "(no subject)"
+			}
+
+			thread, err := ImportUC.forumRepo.CreateThread(models.Thread{Author: author, Forum: forum.Slug, Title: title, Message: message.Body, Created: message.Date, ImportedId: message.Id})
+			if err != nil {
+				return result, err
+			}
+
+			imported[message.Id] = importedMessage{threadId: thread.Id}
+			result.Threads++
+		} else {
+			posts := []models.Post{{Author: author, Message: message.Body, Parent: parent.postId, Mentions: extractMentions(message.Body), ImportedId: message.Id}}
+
+			posts, err = ImportUC.threadRepo.CreatePost(message.Date, "", parent.threadId, posts)
+			if err != nil {
+				return result, err
+			}
+
+			imported[message.Id] = importedMessage{threadId: parent.threadId, postId: posts[0].Id}
+			result.Posts++
+		}
+	}
+
+	return result, nil
+}
+
+func readMailbox(in io.Reader) ([]*mbox.Message, error) {
+	reader := mbox.NewReader(in)
+	messages := make([]*mbox.Message, 0)
+
+	for {
+		message, err := reader.Next()
+		if err == io.EOF {
+			return messages, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if message.Id == "" {
+			sum := sha1.Sum([]byte(message.FromEmail + "\n" + message.Date.String() + "\n" + message.Subject + "\n" + message.Body))
+			message.Id = "<" + hex.EncodeToString(sum[:]) + "@mbox.import>"
+		}
+
+		messages = append(messages, message)
+	}
+}
+
+// parentCandidates lists the Message-IDs a message may answer, nearest first.
+func parentCandidates(message *mbox.Message) []string {
+	candidates := make([]string, 0, len(message.References)+1)
+	if message.InReplyTo != "" {
+		candidates = append(candidates, message.InReplyTo)
+	}
+
+	for iter := len(message.References) - 1; iter >= 0; iter-- {
+		candidates = append(candidates, message.References[iter])
+	}
+
+	return candidates
+}
+
+// replyOrder sorts messages so that every message comes after the one it
+// replies to; siblings stay in date order.
+func (ImportUC ImportUsecaseImpl) replyOrder(messages []*mbox.Message) []*mbox.Message {
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Date.Before(messages[j].Date)
+	})
+
+	byId := make(map[string]*mbox.Message, len(messages))
+	for _, message := range messages {
+		if _, ok := byId[message.Id]; !ok {
+			byId[message.Id] = message
+		}
+	}
+
+	children := make(map[string][]*mbox.Message)
+	roots := make([]*mbox.Message, 0)
+	for _, message := range messages {
+		parentId := ""
+		for _, candidate := range parentCandidates(message) {
+			if _, ok := byId[candidate]; ok && candidate != message.Id {
+				parentId = candidate
+				break
+			}
+		}
+
+		if parentId == "" {
+			roots = append(roots, message)
+		} else {
+			children[parentId] = append(children[parentId], message)
+		}
+	}
+
+	ordered := make([]*mbox.Message, 0, len(messages))
+	visited := make(map[*mbox.Message]bool)
+	var visit func(*mbox.Message)
+	visit = func(message *mbox.Message) {
+		if visited[message] {
+			return
+		}
+		visited[message] = true
+		ordered = append(ordered, message)
+
+		for _, child := range children[message.Id] {
+			visit(child)
+		}
+	}
+
+	for _, root := range roots {
+		visit(root)
+	}
+
+	// Reference cycles leave messages unreachable from any root.
+	for _, message := range messages {
+		visit(message)
+	}
+
+	return ordered
+}
+
+func (ImportUC ImportUsecaseImpl) findParent(forum string, message *mbox.Message, imported map[string]importedMessage) (importedMessage, bool) {
+	for _, candidate := range parentCandidates(message) {
+		if parent, ok := imported[candidate]; ok {
+			return parent, true
+		}
+
+		threadId, postId, err := ImportUC.importRepo.GetImported(forum, candidate)
+		if err == nil {
+			imported[candidate] = importedMessage{threadId: threadId, postId: postId}
+			return imported[candidate], true
+		}
+	}
+
+	return importedMessage{}, false
+}
+
+// ensureAuthor finds the user with the sender's e-mail or creates one with a
+// nickname derived from the local part of the address.
+func (ImportUC ImportUsecaseImpl) ensureAuthor(message *mbox.Message, authors map[string]string) (string, bool, error) {
+	email := message.FromEmail
+	if email == "" {
+		email = "unknown@mbox.invalid"
+	}
+
+	if nickname, ok := authors[email]; ok {
+		return nickname, false, nil
+	}
+
+	nickname, err := ImportUC.importRepo.GetNicknameByEmail(email)
+	if err == nil {
+		authors[email] = nickname
+		return nickname, false, nil
+	}
+
+	if err != pgx.ErrNoRows {
+		return "", false, err
+	}
+
+	base := strings.Trim(nicknameStrip.ReplaceAllString(strings.SplitN(email, "@", 2)[0], ""), ".")
+	if base == "" {
+		base = "user"
+	}
+
+	nickname = base
+	for suffix := 2; ; suffix++ {
+		if _, err = ImportUC.userRepo.GetUserData(nickname); err == pgx.ErrNoRows {
+			break
+		}
+
+		if err != nil {
+			return "", false, err
+		}
+
+		nickname = base + strconv.Itoa(suffix)
+	}
+
+	fullname := message.FromName
+	if fullname == "" {
+		fullname = nickname
+	}
+
+	if runes := []rune(fullname); len(runes) > 100 {
+		fullname = string(runes[:100])
+	}
+
+	if _, err = ImportUC.userRepo.CreateNewUser(models.UserModel{Nickname: nickname, Fullname: fullname, Email: email}); err != nil {
+		return "", false, err
+	}
+
+	authors[email] = nickname
+	return nickname, true, nil
+}
@@ -0,0 +1,54 @@
+package uscases
+
+import (
+	"regexp"
+	"strings"
+
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+)
+
+const maxMentionsPerPost = 50
+
+// A mention starts a word, so e-mail addresses like a@b.ru are not matched.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([\w.]+)`)
+
+type IMentionUsecase interface {
+	GetMentions(string, int, int64) ([]models.Post, error)
+}
+
+type MentionUsecaseImpl struct {
+	mentionRepo repositories.IMentionRepository
+}
+
+func NewMentionUsecaseImpl(mRepo repositories.MentionRepoImpl) MentionUsecaseImpl {
+	return MentionUsecaseImpl{mentionRepo: mRepo}
+}
+
+func (MentionUC MentionUsecaseImpl) GetMentions(nickname string, limit int, since int64) ([]models.Post, error) {
+	return MentionUC.mentionRepo.GetMentions(nickname, limit, since)
+}
+
+// extractMentions returns the distinct @nicknames of a message. They are only
+// candidates: the repository keeps the ones that match existing users.
+func extractMentions(message string) []string {
+	mentions := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(message, -1) {
+		nickname := strings.TrimRight(match[1], ".")
+		key := strings.ToLower(nickname)
+
+		if nickname == "" || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		mentions = append(mentions, nickname)
+		if len(mentions) == maxMentionsPerPost {
+			break
+		}
+	}
+
+	return mentions
+}
@@ -0,0 +1,27 @@
+package uscases
+
+import (
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+)
+
+type INotificationUsecase interface {
+	GetNotifications(string, int, int64, bool) ([]models.Notification, error)
+	MarkRead(string, int64) (models.NotificationsRead, error)
+}
+
+type NotificationUsecaseImpl struct {
+	notificationRepo repositories.INotificationRepository
+}
+
+func NewNotificationUsecaseImpl(nRepo repositories.NotificationRepoImpl) NotificationUsecaseImpl {
+	return NotificationUsecaseImpl{notificationRepo: nRepo}
+}
+
+func (NotificationUC NotificationUsecaseImpl) GetNotifications(nickname string, limit int, since int64, unread bool) ([]models.Notification, error) {
+	return NotificationUC.notificationRepo.GetNotifications(nickname, limit, since, unread)
+}
+
+func (NotificationUC NotificationUsecaseImpl) MarkRead(nickname string, upTo int64) (models.NotificationsRead, error) {
+	return NotificationUC.notificationRepo.MarkRead(nickname, upTo)
+}
@@ -1,27 +1,159 @@
 package uscases
 
 import (
+	"strconv"
+
 	"vk_db_project/app/models"
 	"vk_db_project/app/repositories"
+	"vk_db_project/app/validation"
 )
 
 type IPostUsecase interface {
-	GetPostData(int, []string) (models.FullPost, error)
-	UpdatePost(int64, string) (models.Post, error)
+	GetPostData(int, []string, string, models.RelatedOptions) (models.FullPost, error)
+	GetPostsData([]int64, []string, string) (map[string]models.PostLookup, error)
+	UpdatePost(int64, string, string, int64) (models.Post, error)
+	DeletePost(int64, string) error
 }
 
 type PostUsecaseImpl struct {
-	postRepo repositories.PostRepoImpl
+	postRepo    repositories.PostRepoImpl
+	permissions IPermissionService
+}
+
+func NewPostUsecaseImpl(pRepo repositories.PostRepoImpl, permissions PermissionServiceImpl) PostUsecaseImpl {
+	return PostUsecaseImpl{postRepo: pRepo, permissions: permissions}
+}
+
+const (
+	DefaultChildrenLimit = 20
+	MaxChildrenLimit     = 100
+	DefaultContextSize   = 5
+	MaxContextSize       = 50
+)
+
+func (PostUC PostUsecaseImpl) GetPostData(id int, flags []string, format string, options models.RelatedOptions) (models.FullPost, error) {
+	if err := checkFormat(format); err != nil {
+		return models.FullPost{}, err
+	}
+
+	if options.ChildrenLimit <= 0 {
+		options.ChildrenLimit = DefaultChildrenLimit
+	}
+	if options.ChildrenLimit > MaxChildrenLimit {
+		options.ChildrenLimit = MaxChildrenLimit
+	}
+	if options.ContextSize <= 0 {
+		options.ContextSize = DefaultContextSize
+	}
+	if options.ContextSize > MaxContextSize {
+		options.ContextSize = MaxContextSize
+	}
+
+	data, err := PostUC.postRepo.GetPost(id, flags, options)
+	if err == nil && data.Post != nil {
+		posts := []models.Post{*data.Post}
+		renderPosts(posts, format)
+		data.Post = &posts[0]
+		renderThread(data.Thread, format)
+
+		if data.Parent != nil {
+			parents := []models.Post{*data.Parent}
+			renderPosts(parents, format)
+			data.Parent = &parents[0]
+		}
+		renderPosts(data.Ancestors, format)
+		renderPosts(data.Children, format)
+		if data.Context != nil {
+			renderPosts(data.Context.Before, format)
+			renderPosts(data.Context.After, format)
+		}
+	}
+
+	return data, err
 }
 
-func NewPostUsecaseImpl(pRepo repositories.PostRepoImpl) PostUsecaseImpl {
-	return PostUsecaseImpl{postRepo: pRepo}
+// GetPostsData answers with an entry for every requested id, so a client
+// can tell a missing post from one it forgot to ask for. Keys are strings so
+// the JSON and MessagePack answers look the same.
+func (PostUC PostUsecaseImpl) GetPostsData(ids []int64, flags []string, format string) (map[string]models.PostLookup, error) {
+	if err := checkFormat(format); err != nil {
+		return nil, err
+	}
+	if err := validation.PostIds(ids); err != nil {
+		return nil, err
+	}
+	if err := validation.BatchRelated(flags); err != nil {
+		return nil, err
+	}
+
+	uniqueFlags := make([]string, 0, len(flags))
+	seen := make(map[string]bool)
+	for _, flag := range flags {
+		if !seen[flag] {
+			seen[flag] = true
+			uniqueFlags = append(uniqueFlags, flag)
+		}
+	}
+
+	found, err := PostUC.postRepo.GetPosts(ids, uniqueFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := make(map[string]models.PostLookup, len(ids))
+	for _, id := range ids {
+		key := strconv.FormatInt(id, 10)
+		data, ok := found[id]
+		if !ok {
+			answer[key] = models.PostLookup{Message: "can't find post by id: " + key}
+			continue
+		}
+
+		posts := []models.Post{*data.Post}
+		renderPosts(posts, format)
+		data.Post = &posts[0]
+		answer[key] = models.PostLookup{FullPost: data}
+	}
+
+	// Posts of one thread share the *Thread, render it once.
+	rendered := make(map[*models.Thread]bool)
+	for _, entry := range answer {
+		if entry.Thread != nil && !rendered[entry.Thread] {
+			rendered[entry.Thread] = true
+			renderThread(entry.Thread, format)
+		}
+	}
+
+	return answer, nil
 }
 
-func (PostUC PostUsecaseImpl) GetPostData(id int, flags []string) (models.FullPost, error) {
-	return PostUC.postRepo.GetPost(id, flags)
+func (PostUC PostUsecaseImpl) UpdatePost(id int64, actor string, message string, expectedVersion int64) (models.Post, error) {
+	if err := validation.PostUpdate(models.Post{Message: message}); err != nil {
+		return models.Post{}, err
+	}
+
+	forumSlug, author, err := PostUC.postRepo.SelectPostInfo(id)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if err = PostUC.permissions.Authorize(actor, models.ActionEdit, forumSlug, author); err != nil {
+		return models.Post{}, err
+	}
+
+	return PostUC.postRepo.UpdatePost(models.Post{Id: id, Message: message, Mentions: extractMentions(message)}, expectedVersion)
 }
 
-func (PostUC PostUsecaseImpl) UpdatePost(id int64, message string) (models.Post, error) {
-	return PostUC.postRepo.UpdatePost(models.Post{Id: id, Message: message})
+// DeletePost also deletes the replies under the post.
+func (PostUC PostUsecaseImpl) DeletePost(id int64, actor string) error {
+	forumSlug, author, err := PostUC.postRepo.SelectPostInfo(id)
+	if err != nil {
+		return err
+	}
+
+	if err = PostUC.permissions.Authorize(actor, models.ActionDelete, forumSlug, author); err != nil {
+		return err
+	}
+
+	return PostUC.postRepo.DeletePost(id)
 }
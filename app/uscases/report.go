@@ -0,0 +1,115 @@
+package uscases
+
+import (
+	"strconv"
+
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+	"vk_db_project/app/validation"
+)
+
+type IReportUsecase interface {
+	ReportPost(int64, string, models.Report) (models.Report, bool, error)
+	ReportThread(string, string, models.Report) (models.Report, bool, error)
+	GetReports(string, string, string, int) ([]models.ReportGroup, error)
+	Resolve(string, string, models.Resolution) (models.ReportAction, error)
+}
+
+type ReportUsecaseImpl struct {
+	reportRepo  repositories.IReportRepository
+	threadRepo  repositories.IThreadRepository
+	permissions IPermissionService
+}
+
+func NewReportUsecaseImpl(rRepo repositories.ReportRepoImpl, tRepo repositories.ThreadRepoImpl, permissions PermissionServiceImpl) ReportUsecaseImpl {
+	return ReportUsecaseImpl{reportRepo: rRepo, threadRepo: tRepo, permissions: permissions}
+}
+
+const (
+	DefaultReportsLimit = 20
+	MaxReportsLimit     = 100
+)
+
+func (ReportUC ReportUsecaseImpl) ReportPost(id int64, actor string, report models.Report) (models.Report, bool, error) {
+	return ReportUC.report(models.ReportPost, id, actor, report)
+}
+
+func (ReportUC ReportUsecaseImpl) ReportThread(slugOrId string, actor string, report models.Report) (models.Report, bool, error) {
+	threadId, err := strconv.Atoi(slugOrId)
+	if err != nil {
+		threadId, _, err = ReportUC.threadRepo.SelectThreadInfo(slugOrId, 0)
+		if err != nil {
+			return report, false, err
+		}
+	}
+
+	return ReportUC.report(models.ReportThread, int64(threadId), actor, report)
+}
+
+// report files a report of actor; the flag is false when actor had already
+// reported the target and the open report was updated instead.
+func (ReportUC ReportUsecaseImpl) report(kind string, target int64, actor string, report models.Report) (models.Report, bool, error) {
+	if err := validation.Report(report); err != nil {
+		return report, false, err
+	}
+
+	found, err := ReportUC.reportRepo.SelectTarget(kind, target)
+	if err != nil {
+		return report, false, err
+	}
+
+	if err = ReportUC.permissions.Authorize(actor, models.ActionReport, found.Forum, found.Author); err != nil {
+		return report, false, err
+	}
+
+	found.Reporter = actor
+	found.Category = report.Category
+	found.Text = report.Text
+
+	return ReportUC.reportRepo.CreateReport(found)
+}
+
+func (ReportUC ReportUsecaseImpl) GetReports(slug string, actor string, status string, limit int) ([]models.ReportGroup, error) {
+	if status == "" {
+		status = models.ReportOpen
+	}
+	if err := validation.ReportStatus(status); err != nil {
+		return nil, err
+	}
+
+	if err := ReportUC.permissions.Authorize(actor, models.ActionModerate, slug, ""); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultReportsLimit
+	}
+	if limit > MaxReportsLimit {
+		limit = MaxReportsLimit
+	}
+
+	return ReportUC.reportRepo.GetReports(slug, status, limit)
+}
+
+// Resolve needs a moderator; banning the author also needs a role above
+// theirs, as for any ban.
+func (ReportUC ReportUsecaseImpl) Resolve(slug string, actor string, resolution models.Resolution) (models.ReportAction, error) {
+	answer := models.ReportAction{}
+	if err := validation.Resolution(resolution); err != nil {
+		return answer, err
+	}
+
+	author, err := ReportUC.reportRepo.SelectOpenAuthor(slug, resolution.Type, resolution.Target)
+	if err != nil {
+		return answer, err
+	}
+
+	if resolution.Action != models.ResolveBan {
+		author = ""
+	}
+	if err = ReportUC.permissions.Authorize(actor, models.ActionModerate, slug, author); err != nil {
+		return answer, err
+	}
+
+	return ReportUC.reportRepo.Resolve(slug, resolution, actor)
+}
@@ -0,0 +1,81 @@
+package uscases
+
+import (
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+	"vk_db_project/app/validation"
+)
+
+type IRoleUsecase interface {
+	GetRoles(string, string) ([]models.ForumRole, error)
+	GrantRole(string, string, models.ForumRole) (models.ForumRole, error)
+	RevokeRole(string, string, string) error
+	GetBans(string, string) ([]models.ForumBan, error)
+	Ban(string, string, models.ForumBan) (models.ForumBan, error)
+	Unban(string, string, string) error
+}
+
+type RoleUsecaseImpl struct {
+	roleRepo    repositories.IRoleRepository
+	permissions IPermissionService
+}
+
+func NewRoleUsecaseImpl(rRepo repositories.RoleRepoImpl, permissions PermissionServiceImpl) RoleUsecaseImpl {
+	return RoleUsecaseImpl{roleRepo: rRepo, permissions: permissions}
+}
+
+func (RoleUC RoleUsecaseImpl) GetRoles(slug string, actor string) ([]models.ForumRole, error) {
+	if err := RoleUC.permissions.Authorize(actor, models.ActionView, slug, ""); err != nil {
+		return nil, err
+	}
+
+	return RoleUC.roleRepo.GetRoles(slug)
+}
+
+func (RoleUC RoleUsecaseImpl) GrantRole(slug string, actor string, role models.ForumRole) (models.ForumRole, error) {
+	if err := validation.RoleGrant(role); err != nil {
+		return role, err
+	}
+
+	if err := RoleUC.permissions.Authorize(actor, models.ActionManage, slug, role.Nickname); err != nil {
+		return role, err
+	}
+
+	return RoleUC.roleRepo.GrantRole(slug, role.Nickname, role.Role, actor)
+}
+
+func (RoleUC RoleUsecaseImpl) RevokeRole(slug string, actor string, nickname string) error {
+	if err := RoleUC.permissions.Authorize(actor, models.ActionManage, slug, nickname); err != nil {
+		return err
+	}
+
+	return RoleUC.roleRepo.RevokeRole(slug, nickname)
+}
+
+func (RoleUC RoleUsecaseImpl) GetBans(slug string, actor string) ([]models.ForumBan, error) {
+	if err := RoleUC.permissions.Authorize(actor, models.ActionModerate, slug, ""); err != nil {
+		return nil, err
+	}
+
+	return RoleUC.roleRepo.GetBans(slug)
+}
+
+func (RoleUC RoleUsecaseImpl) Ban(slug string, actor string, ban models.ForumBan) (models.ForumBan, error) {
+	if err := validation.Ban(ban); err != nil {
+		return ban, err
+	}
+
+	if err := RoleUC.permissions.Authorize(actor, models.ActionModerate, slug, ban.Nickname); err != nil {
+		return ban, err
+	}
+
+	return RoleUC.roleRepo.Ban(slug, ban.Nickname, actor, ban.Reason)
+}
+
+func (RoleUC RoleUsecaseImpl) Unban(slug string, actor string, nickname string) error {
+	if err := RoleUC.permissions.Authorize(actor, models.ActionModerate, slug, nickname); err != nil {
+		return err
+	}
+
+	return RoleUC.roleRepo.Unban(slug, nickname)
+}
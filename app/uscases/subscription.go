@@ -0,0 +1,107 @@
+package uscases
+
+import (
+	"strconv"
+
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+	"vk_db_project/app/validation"
+)
+
+type ISubscriptionUsecase interface {
+	Subscribe(string, string) (models.Subscription, error)
+	Unsubscribe(string, string) error
+	MarkSeen(string, string, int64) (models.Subscription, error)
+	GetSubscriptions(string, int, int64) ([]models.Subscription, error)
+	GetSettings(string) (models.SubscriptionSettings, error)
+	UpdateSettings(string, models.SubscriptionSettings) (models.SubscriptionSettings, error)
+}
+
+// SubscriptionUsecaseImpl only talks to interfaces, so it can be exercised
+// with fake repositories and no database.
+type SubscriptionUsecaseImpl struct {
+	subscriptionRepo repositories.ISubscriptionRepository
+	threadRepo       repositories.IThreadRepository
+	permissions      IPermissionService
+}
+
+func NewSubscriptionUsecaseImpl(sRepo repositories.SubscriptionRepoImpl, tRepo repositories.ThreadRepoImpl, permissions PermissionServiceImpl) SubscriptionUsecaseImpl {
+	return SubscriptionUsecaseImpl{subscriptionRepo: sRepo, threadRepo: tRepo, permissions: permissions}
+}
+
+// authorize resolves the thread and checks that the subscriber is not
+// banned in its forum. Unsubscribing needs no check, so banned users can
+// still stop their notifications.
+func (SubscriptionUC SubscriptionUsecaseImpl) authorize(nickname string, slugOrId string) (int, error) {
+	slug, id := splitSlugOrId(slugOrId)
+
+	threadId, forumSlug, err := SubscriptionUC.threadRepo.SelectThreadInfo(slug, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return threadId, SubscriptionUC.permissions.Authorize(nickname, models.ActionSubscribe, forumSlug, "")
+}
+
+func splitSlugOrId(slugOrId string) (string, int) {
+	id, err := strconv.Atoi(slugOrId)
+	if err != nil {
+		return slugOrId, 0
+	}
+
+	return "", id
+}
+
+func (SubscriptionUC SubscriptionUsecaseImpl) Subscribe(nickname string, slugOrId string) (models.Subscription, error) {
+	check := new(validation.Checker)
+	check.Required("nickname", nickname)
+	if err := check.Err(); err != nil {
+		return models.Subscription{}, err
+	}
+
+	threadId, err := SubscriptionUC.authorize(nickname, slugOrId)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	return SubscriptionUC.subscriptionRepo.Subscribe(nickname, "", threadId)
+}
+
+func (SubscriptionUC SubscriptionUsecaseImpl) Unsubscribe(nickname string, slugOrId string) error {
+	check := new(validation.Checker)
+	check.Required("nickname", nickname)
+	if err := check.Err(); err != nil {
+		return err
+	}
+
+	slug, id := splitSlugOrId(slugOrId)
+
+	return SubscriptionUC.subscriptionRepo.Unsubscribe(nickname, slug, id)
+}
+
+// MarkSeen resets the new posts of a subscription up to lastSeen, all of them
+// when it is zero. Subscribing again and posting in the thread do the same.
+func (SubscriptionUC SubscriptionUsecaseImpl) MarkSeen(nickname string, slugOrId string, lastSeen int64) (models.Subscription, error) {
+	if err := validation.MarkSeen(nickname, lastSeen); err != nil {
+		return models.Subscription{}, err
+	}
+
+	threadId, err := SubscriptionUC.authorize(nickname, slugOrId)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	return SubscriptionUC.subscriptionRepo.MarkSeen(nickname, "", threadId, lastSeen)
+}
+
+func (SubscriptionUC SubscriptionUsecaseImpl) GetSubscriptions(nickname string, limit int, since int64) ([]models.Subscription, error) {
+	return SubscriptionUC.subscriptionRepo.GetSubscriptions(nickname, limit, since)
+}
+
+func (SubscriptionUC SubscriptionUsecaseImpl) GetSettings(nickname string) (models.SubscriptionSettings, error) {
+	return SubscriptionUC.subscriptionRepo.GetSettings(nickname)
+}
+
+func (SubscriptionUC SubscriptionUsecaseImpl) UpdateSettings(nickname string, settings models.SubscriptionSettings) (models.SubscriptionSettings, error) {
+	return SubscriptionUC.subscriptionRepo.UpdateSettings(nickname, settings)
+}
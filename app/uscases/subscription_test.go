@@ -0,0 +1,212 @@
+package uscases
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx"
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+	"vk_db_project/app/validation"
+)
+
+// fakeThreadRepo only knows thread 42 of forum "pirates", by id or by the
+// slug "my-thread".
+type fakeThreadRepo struct {
+	repositories.IThreadRepository
+	slug string
+	id   int
+}
+
+func (Fake *fakeThreadRepo) SelectThreadInfo(slug string, id int) (int, string, error) {
+	Fake.slug, Fake.id = slug, id
+	if slug == "my-thread" || id == 42 {
+		return 42, "pirates", nil
+	}
+
+	return 0, "", pgx.ErrNoRows
+}
+
+// fakePermissions answers every check with err and remembers the last one.
+type fakePermissions struct {
+	calls  int
+	actor  string
+	action string
+	forum  string
+	err    error
+}
+
+func (Fake *fakePermissions) Authorize(actor string, action string, forum string, target string) error {
+	Fake.calls++
+	Fake.actor, Fake.action, Fake.forum = actor, action, forum
+	return Fake.err
+}
+
+func (Fake *fakePermissions) AuthorizeAuthors(forum string, authors []string) error {
+	return Fake.err
+}
+
+func newFakeSubscriptions() (SubscriptionUsecaseImpl, *fakeSubscriptionRepo, *fakeThreadRepo, *fakePermissions) {
+	repo := &fakeSubscriptionRepo{}
+	threads := &fakeThreadRepo{}
+	permissions := &fakePermissions{}
+
+	return SubscriptionUsecaseImpl{subscriptionRepo: repo, threadRepo: threads, permissions: permissions}, repo, threads, permissions
+}
+
+// fakeSubscriptionRepo records the last call and answers with err.
+type fakeSubscriptionRepo struct {
+	calls    int
+	nickname string
+	slug     string
+	id       int
+	lastSeen int64
+	err      error
+}
+
+func (Fake *fakeSubscriptionRepo) record(nickname string, slug string, id int) {
+	Fake.calls++
+	Fake.nickname, Fake.slug, Fake.id = nickname, slug, id
+}
+
+func (Fake *fakeSubscriptionRepo) Subscribe(nickname string, slug string, id int) (models.Subscription, error) {
+	Fake.record(nickname, slug, id)
+	return models.Subscription{Nickname: nickname}, Fake.err
+}
+
+func (Fake *fakeSubscriptionRepo) Unsubscribe(nickname string, slug string, id int) error {
+	Fake.record(nickname, slug, id)
+	return Fake.err
+}
+
+func (Fake *fakeSubscriptionRepo) MarkSeen(nickname string, slug string, id int, lastSeen int64) (models.Subscription, error) {
+	Fake.record(nickname, slug, id)
+	Fake.lastSeen = lastSeen
+	return models.Subscription{Nickname: nickname, LastSeen: lastSeen}, Fake.err
+}
+
+func (Fake *fakeSubscriptionRepo) GetSubscriptions(nickname string, limit int, since int64) ([]models.Subscription, error) {
+	Fake.calls++
+	return nil, Fake.err
+}
+
+func (Fake *fakeSubscriptionRepo) GetSettings(nickname string) (models.SubscriptionSettings, error) {
+	Fake.calls++
+	return models.SubscriptionSettings{}, Fake.err
+}
+
+func (Fake *fakeSubscriptionRepo) UpdateSettings(nickname string, settings models.SubscriptionSettings) (models.SubscriptionSettings, error) {
+	Fake.calls++
+	return settings, Fake.err
+}
+
+func TestSubscribeRequiresNickname(t *testing.T) {
+	logic, repo, _, _ := newFakeSubscriptions()
+
+	_, err := logic.Subscribe("  ", "42")
+	if _, ok := err.(validation.Errors); !ok {
+		t.Fatalf("Subscribe with a blank nickname: got %v, want validation errors", err)
+	}
+	if repo.calls != 0 {
+		t.Fatalf("repository called %d times for an invalid request", repo.calls)
+	}
+}
+
+func TestSubscribeResolvesSlugOrId(t *testing.T) {
+	tests := []struct {
+		slugOrId string
+		slug     string
+		id       int
+	}{
+		{"42", "", 42},
+		{"my-thread", "my-thread", 0},
+	}
+
+	for _, test := range tests {
+		logic, repo, threads, permissions := newFakeSubscriptions()
+
+		if _, err := logic.Subscribe("tester", test.slugOrId); err != nil {
+			t.Fatalf("Subscribe(%q): %v", test.slugOrId, err)
+		}
+		if threads.slug != test.slug || threads.id != test.id {
+			t.Errorf("Subscribe(%q) looked up slug %q, id %d; want slug %q, id %d",
+				test.slugOrId, threads.slug, threads.id, test.slug, test.id)
+		}
+		if repo.nickname != "tester" || repo.id != 42 {
+			t.Errorf("Subscribe(%q) subscribed %q to thread %d, want tester to 42", test.slugOrId, repo.nickname, repo.id)
+		}
+		if permissions.actor != "tester" || permissions.action != models.ActionSubscribe || permissions.forum != "pirates" {
+			t.Errorf("Subscribe(%q) checked %q %q in %q, want tester subscribe in pirates",
+				test.slugOrId, permissions.actor, permissions.action, permissions.forum)
+		}
+	}
+}
+
+func TestSubscribeUnknownThread(t *testing.T) {
+	logic, repo, _, _ := newFakeSubscriptions()
+
+	if _, err := logic.Subscribe("tester", "42abc"); err != pgx.ErrNoRows {
+		t.Fatalf("Subscribe to an unknown thread: got %v, want %v", err, pgx.ErrNoRows)
+	}
+	if repo.calls != 0 {
+		t.Fatalf("repository called %d times for an unknown thread", repo.calls)
+	}
+}
+
+func TestSubscribeBanned(t *testing.T) {
+	logic, repo, _, permissions := newFakeSubscriptions()
+	permissions.err = forumErrors.Banned
+
+	if _, err := logic.Subscribe("tester", "42"); err != forumErrors.Banned {
+		t.Fatalf("Subscribe of a banned user: got %v, want %v", err, forumErrors.Banned)
+	}
+	if repo.calls != 0 {
+		t.Fatalf("repository called %d times for a banned user", repo.calls)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	logic, repo, _, permissions := newFakeSubscriptions()
+	repo.err = forumErrors.NotSubscribed
+	permissions.err = forumErrors.Banned
+
+	if err := logic.Unsubscribe("tester", "7"); err != forumErrors.NotSubscribed {
+		t.Fatalf("Unsubscribe: got %v, want %v", err, forumErrors.NotSubscribed)
+	}
+	if repo.id != 7 {
+		t.Fatalf("Unsubscribe asked for thread %d, want 7", repo.id)
+	}
+	if permissions.calls != 0 {
+		t.Fatalf("Unsubscribe checked permissions %d times, banned users may always leave", permissions.calls)
+	}
+}
+
+func TestMarkSeen(t *testing.T) {
+	logic, repo, _, _ := newFakeSubscriptions()
+
+	subscription, err := logic.MarkSeen("tester", "my-thread", 15)
+	if err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	if repo.id != 42 || repo.lastSeen != 15 || subscription.LastSeen != 15 {
+		t.Fatalf("MarkSeen asked for thread %d up to %d, want 42 up to 15", repo.id, repo.lastSeen)
+	}
+
+	repo.err = forumErrors.NotSubscribed
+	if _, err := logic.MarkSeen("tester", "my-thread", 0); err != forumErrors.NotSubscribed {
+		t.Fatalf("MarkSeen of an unfollowed thread: got %v, want %v", err, forumErrors.NotSubscribed)
+	}
+}
+
+func TestMarkSeenValidates(t *testing.T) {
+	logic, repo, _, _ := newFakeSubscriptions()
+
+	_, err := logic.MarkSeen("", "1", -1)
+	fieldErrors, ok := err.(validation.Errors)
+	if !ok || len(fieldErrors) != 2 {
+		t.Fatalf("MarkSeen without nickname and with a negative lastSeen: got %v, want two field errors", err)
+	}
+	if repo.calls != 0 {
+		t.Fatalf("repository called %d times for an invalid request", repo.calls)
+	}
+}
@@ -0,0 +1,25 @@
+package uscases
+
+import "strings"
+
+// normalizeTags trims and lowercases tags, dropping empty and repeated ones
+// but keeping the order. nil stays nil, so an update can leave tags alone.
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
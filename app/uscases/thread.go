@@ -1,30 +1,40 @@
 package uscases
 
 import (
+	"io"
 	"strconv"
 	"time"
 
 	"vk_db_project/app/models"
 	"vk_db_project/app/repositories"
+	"vk_db_project/app/validation"
 )
 
 type IThreadUsecase interface {
 	CreatePosts(string, []models.Post) ([]models.Post, error)
 	VoteThread(string, string, int) (models.Thread, error)
-	GetThread(string) (models.Thread, error)
-	GetPosts(string, int, int, string, bool) ([]models.Post, error)
-	UpdateThread(string, models.Thread) (models.Thread, error)
+	GetThread(string, string) (models.Thread, error)
+	GetThreadsByIds([]int64) ([]models.Thread, error)
+	GetPosts(string, int, int, string, bool, string) ([]models.Post, error)
+	UpdateThread(string, string, models.Thread, int64) (models.Thread, error)
+	ExportThread(string, string, func(models.Thread) io.Writer) error
+	LockThread(string, string, bool) (models.Thread, error)
+	DeleteThread(string, string) error
 }
 
 type ThreadsUsecaseImpl struct {
-	threadRepo repositories.IThreadRepository
+	threadRepo  repositories.IThreadRepository
+	permissions IPermissionService
 }
 
-func NewThreadsUsecaseImpl(tRepo repositories.ThreadRepoImpl) ThreadsUsecaseImpl {
-	return ThreadsUsecaseImpl{threadRepo: tRepo}
+func NewThreadsUsecaseImpl(tRepo repositories.ThreadRepoImpl, permissions PermissionServiceImpl) ThreadsUsecaseImpl {
+	return ThreadsUsecaseImpl{threadRepo: tRepo, permissions: permissions}
 }
 
 func (ThreadUC ThreadsUsecaseImpl) CreatePosts(slugOrId string, posts []models.Post) ([]models.Post, error) {
+	if err := validation.Posts(posts); err != nil {
+		return nil, err
+	}
 
 	id, err := strconv.Atoi(slugOrId)
 
@@ -34,6 +44,26 @@ func (ThreadUC ThreadsUsecaseImpl) CreatePosts(slugOrId string, posts []models.P
 		slugOrId = ""
 	}
 
+	if len(posts) != 0 {
+		_, forumSlug, err := ThreadUC.threadRepo.SelectThreadInfo(slugOrId, id)
+		if err != nil {
+			return nil, err
+		}
+
+		authors := make([]string, 0, len(posts))
+		for _, post := range posts {
+			authors = append(authors, post.Author)
+		}
+
+		if err = ThreadUC.permissions.AuthorizeAuthors(forumSlug, authors); err != nil {
+			return nil, err
+		}
+	}
+
+	for iter, _ := range posts {
+		posts[iter].Mentions = extractMentions(posts[iter].Message)
+	}
+
 	t := time.Now()
 
 	return ThreadUC.threadRepo.CreatePost(t, slugOrId, id, posts)
@@ -49,10 +79,22 @@ func (ThreadUC ThreadsUsecaseImpl) VoteThread(slug, nickname string, voice int)
 		slug = ""
 	}
 
+	_, forumSlug, err := ThreadUC.threadRepo.SelectThreadInfo(slug, threadId)
+	if err != nil {
+		return models.Thread{}, err
+	}
+
+	if err = ThreadUC.permissions.AuthorizeAuthors(forumSlug, []string{nickname}); err != nil {
+		return models.Thread{}, err
+	}
+
 	return ThreadUC.threadRepo.VoteThread(nickname, voice, threadId, models.Thread{Slug: slug})
 }
 
-func (ThreadUC ThreadsUsecaseImpl) GetThread(slug string) (models.Thread, error) {
+func (ThreadUC ThreadsUsecaseImpl) GetThread(slug string, format string) (models.Thread, error) {
+	if err := checkFormat(format); err != nil {
+		return models.Thread{}, err
+	}
 
 	threadId, err := strconv.Atoi(slug)
 
@@ -62,10 +104,22 @@ func (ThreadUC ThreadsUsecaseImpl) GetThread(slug string) (models.Thread, error)
 		slug = ""
 	}
 
-	return ThreadUC.threadRepo.GetThread(threadId, models.Thread{Slug: slug})
+	thread, err := ThreadUC.threadRepo.GetThread(threadId, models.Thread{Slug: slug})
+	if err == nil {
+		renderThread(&thread, format)
+	}
+
+	return thread, err
 }
 
-func (ThreadUC ThreadsUsecaseImpl) GetPosts(slugOrId string, limit int, since int, sortType string, desc bool) ([]models.Post, error) {
+func (ThreadUC ThreadsUsecaseImpl) GetThreadsByIds(ids []int64) ([]models.Thread, error) {
+	return ThreadUC.threadRepo.GetThreadsByIds(ids)
+}
+
+func (ThreadUC ThreadsUsecaseImpl) GetPosts(slugOrId string, limit int, since int, sortType string, desc bool, format string) ([]models.Post, error) {
+	if err := checkFormat(format); err != nil {
+		return nil, err
+	}
 
 	threadId, err := strconv.Atoi(slugOrId)
 
@@ -76,10 +130,15 @@ func (ThreadUC ThreadsUsecaseImpl) GetPosts(slugOrId string, limit int, since in
 	}
 
 	data, err := ThreadUC.threadRepo.GetPostsSorted(slugOrId, threadId, limit, since, sortType, desc)
+	if err == nil {
+		renderPosts(data, format)
+	}
+
 	return data, err
 }
 
-func (ThreadUC ThreadsUsecaseImpl) UpdateThread(slugOrId string, newThreadData models.Thread) (models.Thread, error) {
+// findThread looks up the thread the permission service is asked about.
+func (ThreadUC ThreadsUsecaseImpl) findThread(slugOrId string) (models.Thread, error) {
 	threadId, err := strconv.Atoi(slugOrId)
 
 	if err != nil {
@@ -88,5 +147,49 @@ func (ThreadUC ThreadsUsecaseImpl) UpdateThread(slugOrId string, newThreadData m
 		slugOrId = ""
 	}
 
-	return ThreadUC.threadRepo.UpdateThread(slugOrId, threadId, newThreadData)
+	return ThreadUC.threadRepo.GetThread(threadId, models.Thread{Slug: slugOrId})
+}
+
+func (ThreadUC ThreadsUsecaseImpl) UpdateThread(slugOrId string, actor string, newThreadData models.Thread, expectedVersion int64) (models.Thread, error) {
+	newThreadData.Tags = normalizeTags(newThreadData.Tags)
+	if err := validation.ThreadUpdate(newThreadData); err != nil {
+		return newThreadData, err
+	}
+
+	thread, err := ThreadUC.findThread(slugOrId)
+	if err != nil {
+		return newThreadData, err
+	}
+
+	if err = ThreadUC.permissions.Authorize(actor, models.ActionEdit, thread.Forum, thread.Author); err != nil {
+		return newThreadData, err
+	}
+
+	return ThreadUC.threadRepo.UpdateThread("", thread.Id, newThreadData, expectedVersion)
+}
+
+func (ThreadUC ThreadsUsecaseImpl) LockThread(slugOrId string, actor string, locked bool) (models.Thread, error) {
+	thread, err := ThreadUC.findThread(slugOrId)
+	if err != nil {
+		return thread, err
+	}
+
+	if err = ThreadUC.permissions.Authorize(actor, models.ActionModerate, thread.Forum, ""); err != nil {
+		return thread, err
+	}
+
+	return ThreadUC.threadRepo.LockThread("", thread.Id, locked)
+}
+
+func (ThreadUC ThreadsUsecaseImpl) DeleteThread(slugOrId string, actor string) error {
+	thread, err := ThreadUC.findThread(slugOrId)
+	if err != nil {
+		return err
+	}
+
+	if err = ThreadUC.permissions.Authorize(actor, models.ActionDelete, thread.Forum, thread.Author); err != nil {
+		return err
+	}
+
+	return ThreadUC.threadRepo.DeleteThread(thread.Id)
 }
@@ -3,10 +3,13 @@ package uscases
 import (
 	"vk_db_project/app/models"
 	"vk_db_project/app/repositories"
+	"vk_db_project/app/validation"
 )
 
 type IUserUsecase interface {
 	GetUser(string) (models.UserModel, error)
+	GetUsers([]string) ([]models.UserModel, error)
+	GetUserStats(string) (models.UserStats, error)
 	CreateUser(models.UserModel) (interface{}, error)
 	UpdateUserData(models.UserModel) (models.UserModel, error)
 	GetServerStatus() models.Status
@@ -25,7 +28,30 @@ func (UserUC UserUsecaseImpl) GetUser(nickname string) (models.UserModel, error)
 	return UserUC.userRepo.GetUserData(nickname)
 }
 
+func (UserUC UserUsecaseImpl) GetUsers(nicknames []string) ([]models.UserModel, error) {
+	return UserUC.userRepo.GetUsersByNicknames(nicknames)
+}
+
+// Karma weights: a vote on one of the user's threads counts the most, then
+// starting a thread, then a post.
+const (
+	karmaPerVote   = 10
+	karmaPerThread = 2
+	karmaPerPost   = 1
+)
+
+func (UserUC UserUsecaseImpl) GetUserStats(nickname string) (models.UserStats, error) {
+	stats, err := UserUC.userRepo.GetUserStats(nickname)
+	stats.Karma = karmaPerVote*stats.Votes + karmaPerThread*stats.Threads + karmaPerPost*stats.Posts
+
+	return stats, err
+}
+
 func (UserUC UserUsecaseImpl) CreateUser(newUser models.UserModel) (interface{}, error) {
+	if err := validation.User(newUser); err != nil {
+		return nil, err
+	}
+
 	answerData, err := UserUC.userRepo.CreateNewUser(newUser)
 	if err != nil {
 		return answerData, err
@@ -35,6 +61,10 @@ func (UserUC UserUsecaseImpl) CreateUser(newUser models.UserModel) (interface{},
 }
 
 func (UserUC UserUsecaseImpl) UpdateUserData(newUserData models.UserModel) (models.UserModel, error) {
+	if err := validation.UserUpdate(newUserData); err != nil {
+		return newUserData, err
+	}
+
 	return UserUC.userRepo.UpdateUserData(newUserData)
 }
 
@@ -0,0 +1,204 @@
+package uscases
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	forumErrors "vk_db_project/app/errors"
+	"vk_db_project/app/models"
+	"vk_db_project/app/repositories"
+)
+
+const (
+	webhookBatchSize   = 20
+	webhookMaxAttempts = 8
+	webhookBaseBackoff = 5 * time.Second
+	webhookMaxBackoff  = time.Hour
+	webhookTimeout     = 10 * time.Second
+)
+
+type IWebhookUsecase interface {
+	CreateWebhook(string, string, models.Webhook) (models.Webhook, error)
+	GetWebhooks(string, string) ([]models.Webhook, error)
+	GetDeliveries(string, string, int64, int, int64) ([]models.WebhookDelivery, error)
+	DeliverPending() int
+	RunDeliveryWorker(time.Duration)
+}
+
+type WebhookUsecaseImpl struct {
+	webhookRepo repositories.IWebhookRepository
+	permissions IPermissionService
+	client      *http.Client
+}
+
+func NewWebhookUsecaseImpl(wRepo repositories.WebhookRepoImpl, permissions PermissionServiceImpl) WebhookUsecaseImpl {
+	return WebhookUsecaseImpl{webhookRepo: wRepo, permissions: permissions, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// Webhooks push every post of the forum to a URL of their creator and the
+// delivery log keeps the payloads, so only moderators and the owner manage
+// and read them.
+func (WebhookUC WebhookUsecaseImpl) CreateWebhook(slug string, actor string, webhook models.Webhook) (models.Webhook, error) {
+	if err := WebhookUC.permissions.Authorize(actor, models.ActionModerate, slug, ""); err != nil {
+		return webhook, err
+	}
+
+	target, err := url.Parse(webhook.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return webhook, forumErrors.InvalidUrl
+	}
+
+	if len(webhook.Events) == 0 {
+		webhook.Events = models.WebhookEvents
+	}
+
+	for _, event := range webhook.Events {
+		if !isWebhookEvent(event) {
+			return webhook, forumErrors.InvalidEvent
+		}
+	}
+
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			return webhook, err
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+
+	webhook.Forum = slug
+	return WebhookUC.webhookRepo.CreateWebhook(webhook)
+}
+
+func (WebhookUC WebhookUsecaseImpl) GetWebhooks(slug string, actor string) ([]models.Webhook, error) {
+	if err := WebhookUC.permissions.Authorize(actor, models.ActionModerate, slug, ""); err != nil {
+		return nil, err
+	}
+
+	return WebhookUC.webhookRepo.GetWebhooks(slug)
+}
+
+func (WebhookUC WebhookUsecaseImpl) GetDeliveries(slug string, actor string, id int64, limit int, since int64) ([]models.WebhookDelivery, error) {
+	if err := WebhookUC.permissions.Authorize(actor, models.ActionModerate, slug, ""); err != nil {
+		return nil, err
+	}
+
+	return WebhookUC.webhookRepo.GetDeliveries(slug, id, limit, since)
+}
+
+// DeliverPending sends one batch of due outbox events and returns how many
+// were attempted.
+func (WebhookUC WebhookUsecaseImpl) DeliverPending() int {
+	events, err := WebhookUC.webhookRepo.ClaimEvents(webhookBatchSize, webhookTimeout*2)
+	if err != nil {
+		fmt.Println("webhooks: claim events:", err)
+		return 0
+	}
+
+	for _, event := range events {
+		delivery := WebhookUC.deliver(event)
+		nextAttempt := time.Now()
+
+		switch {
+		case delivery.Status == "delivered":
+		case event.Attempts >= webhookMaxAttempts:
+			delivery.Status = "failed"
+		default:
+			delivery.Status = "pending"
+			nextAttempt = nextAttempt.Add(webhookBackoff(event.Attempts))
+		}
+
+		if err = WebhookUC.webhookRepo.SaveDelivery(event, delivery, nextAttempt); err != nil {
+			fmt.Println("webhooks: save delivery:", err)
+		}
+	}
+
+	return len(events)
+}
+
+func (WebhookUC WebhookUsecaseImpl) RunDeliveryWorker(interval time.Duration) {
+	for {
+		if WebhookUC.DeliverPending() < webhookBatchSize {
+			time.Sleep(interval)
+		}
+	}
+}
+
+func (WebhookUC WebhookUsecaseImpl) deliver(event models.WebhookEvent) models.WebhookDelivery {
+	delivery := models.WebhookDelivery{Webhook: event.Webhook, EventId: event.Id, Event: event.Event, Attempt: event.Attempts}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request, err := http.NewRequest(http.MethodPost, event.Url, bytes.NewReader(event.Payload))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "forum-webhooks/1.0")
+	request.Header.Set("X-Forum-Event", event.Event)
+	request.Header.Set("X-Forum-Delivery", strconv.FormatInt(event.Id, 10))
+	request.Header.Set("X-Forum-Timestamp", timestamp)
+	request.Header.Set("X-Forum-Signature", "sha256="+signWebhook(event.Secret, timestamp, event.Payload))
+
+	start := time.Now()
+	response, err := WebhookUC.client.Do(request)
+	delivery.Duration = time.Since(start).Milliseconds()
+
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	response.Body.Close()
+
+	delivery.StatusCode = response.StatusCode
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		delivery.Status = "delivered"
+	} else {
+		delivery.Error = response.Status
+	}
+
+	return delivery
+}
+
+// signWebhook signs "<timestamp>.<body>" so receivers can reject replays of
+// old payloads as well as forged ones.
+func signWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+
+	return backoff
+}
+
+func isWebhookEvent(event string) bool {
+	for _, known := range models.WebhookEvents {
+		if event == known {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,403 @@
+// Package validation holds the input rules shared by the usecases. Every
+// check records a failure instead of stopping, so a client gets the full
+// list of fields to fix in one 400 response.
+package validation
+
+import (
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"vk_db_project/app/models"
+)
+
+const (
+	MaxNickname = 64
+	MaxSlug     = 128
+	MaxEmail    = 254
+	MaxFullname = 100
+	MaxAbout    = 4096
+	MaxTitle    = 256
+	MaxMessage  = 65536
+	MaxPostIds  = 100
+	MaxBuckets  = 1000
+	MaxTags     = 10
+	MaxTag      = 32
+	MaxReason   = 1024
+)
+
+var (
+	nicknamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+	slugPattern     = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	digitsPattern   = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// Errors is returned by the usecases when the input breaks a rule.
+type Errors []models.FieldError
+
+func (Errors Errors) Error() string {
+	messages := make([]string, 0, len(Errors))
+	for _, fieldError := range Errors {
+		messages = append(messages, fieldError.Field+" "+fieldError.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+type Checker struct {
+	errors Errors
+}
+
+func (Check *Checker) fail(field, message string) {
+	Check.errors = append(Check.errors, models.FieldError{Field: field, Message: message})
+}
+
+// Err returns nil when every check passed.
+func (Check *Checker) Err() error {
+	if len(Check.errors) == 0 {
+		return nil
+	}
+
+	return Check.errors
+}
+
+func (Check *Checker) Required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		Check.fail(field, "is required")
+		return false
+	}
+
+	return true
+}
+
+func (Check *Checker) MaxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		Check.fail(field, "must be at most "+strconv.Itoa(max)+" characters")
+	}
+}
+
+func (Check *Checker) OneOf(field, value string, allowed ...string) {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+
+	Check.fail(field, "must be one of: "+strings.Join(allowed, ", "))
+}
+
+func (Check *Checker) Nickname(field, value string) {
+	if !Check.Required(field, value) {
+		return
+	}
+
+	Check.MaxLength(field, value, MaxNickname)
+	if !nicknamePattern.MatchString(value) {
+		Check.fail(field, "may contain only latin letters, digits, '_' and '.'")
+	}
+}
+
+func (Check *Checker) Slug(field, value string) {
+	if !Check.Required(field, value) {
+		return
+	}
+
+	Check.MaxLength(field, value, MaxSlug)
+	if !slugPattern.MatchString(value) {
+		Check.fail(field, "may contain only latin letters, digits, '_' and '-'")
+	}
+}
+
+// ThreadSlug is optional, but an all-digit one would be read as an id by
+// /api/thread/:slug_or_id and the thread could never be reached by slug.
+func (Check *Checker) ThreadSlug(field, value string) {
+	if value == "" {
+		return
+	}
+
+	Check.Slug(field, value)
+	if digitsPattern.MatchString(value) {
+		Check.fail(field, "must not consist of digits only")
+	}
+}
+
+func (Check *Checker) Email(field, value string) {
+	if !Check.Required(field, value) {
+		return
+	}
+
+	Check.MaxLength(field, value, MaxEmail)
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value {
+		Check.fail(field, "must be a valid e-mail address")
+	}
+}
+
+func User(user models.UserModel) error {
+	check := new(Checker)
+	check.Nickname("nickname", user.Nickname)
+	check.Email("email", user.Email)
+	if check.Required("fullname", user.Fullname) {
+		check.MaxLength("fullname", user.Fullname, MaxFullname)
+	}
+	check.MaxLength("about", user.About, MaxAbout)
+
+	return check.Err()
+}
+
+// UserUpdate only checks the fields that are being changed.
+func UserUpdate(user models.UserModel) error {
+	check := new(Checker)
+	if user.Email != "" {
+		check.Email("email", user.Email)
+	}
+	check.MaxLength("fullname", user.Fullname, MaxFullname)
+	check.MaxLength("about", user.About, MaxAbout)
+
+	return check.Err()
+}
+
+func Forum(forum models.Forum) error {
+	check := new(Checker)
+	check.Slug("slug", forum.Slug)
+	if check.Required("title", forum.Title) {
+		check.MaxLength("title", forum.Title, MaxTitle)
+	}
+	check.Required("user", forum.User)
+	if forum.Parent != "" {
+		check.Slug("parent", forum.Parent)
+	}
+
+	return check.Err()
+}
+
+// ForumMove checks the new parent of a forum; empty makes it top level.
+func ForumMove(parent string) error {
+	check := new(Checker)
+	if parent != "" {
+		check.Slug("parent", parent)
+	}
+
+	return check.Err()
+}
+
+// Tags checks tags that are already normalized.
+func (Check *Checker) Tags(field string, tags []string) {
+	if len(tags) > MaxTags {
+		Check.fail(field, "must have at most "+strconv.Itoa(MaxTags)+" items")
+	}
+	for iter, tag := range tags {
+		Check.MaxLength(field+"["+strconv.Itoa(iter)+"]", tag, MaxTag)
+	}
+}
+
+func Thread(thread models.Thread) error {
+	check := new(Checker)
+	check.ThreadSlug("slug", thread.Slug)
+	if check.Required("title", thread.Title) {
+		check.MaxLength("title", thread.Title, MaxTitle)
+	}
+	if check.Required("message", thread.Message) {
+		check.MaxLength("message", thread.Message, MaxMessage)
+	}
+	check.Required("author", thread.Author)
+	check.Tags("tags", thread.Tags)
+
+	return check.Err()
+}
+
+func ThreadUpdate(thread models.Thread) error {
+	check := new(Checker)
+	check.MaxLength("title", thread.Title, MaxTitle)
+	check.MaxLength("message", thread.Message, MaxMessage)
+	check.Tags("tags", thread.Tags)
+
+	return check.Err()
+}
+
+func ThreadFilter(filter models.ThreadFilter) error {
+	check := new(Checker)
+	check.Tags("tag", filter.Tags)
+	check.OneOf("tagMode", filter.Mode, models.TagModeAll, models.TagModeAny)
+
+	return check.Err()
+}
+
+func Posts(posts []models.Post) error {
+	check := new(Checker)
+	for iter, post := range posts {
+		prefix := "[" + strconv.Itoa(iter) + "]."
+		check.Required(prefix+"author", post.Author)
+		check.MaxLength(prefix+"message", post.Message, MaxMessage)
+	}
+
+	return check.Err()
+}
+
+func PostUpdate(post models.Post) error {
+	check := new(Checker)
+	check.MaxLength("message", post.Message, MaxMessage)
+
+	return check.Err()
+}
+
+func PostIds(ids []int64) error {
+	check := new(Checker)
+	if len(ids) == 0 {
+		check.fail("ids", "is required")
+	}
+	if len(ids) > MaxPostIds {
+		check.fail("ids", "must have at most "+strconv.Itoa(MaxPostIds)+" items")
+	}
+	for iter, id := range ids {
+		if id <= 0 {
+			check.fail("ids["+strconv.Itoa(iter)+"]", "must be a positive id")
+		}
+	}
+
+	return check.Err()
+}
+
+// BatchRelated only allows the expansions shared by all posts of a batch;
+// parent, ancestors, children and context are single post lookups.
+func BatchRelated(flags []string) error {
+	check := new(Checker)
+	for _, flag := range flags {
+		if flag != "" {
+			check.OneOf("related", flag, "user", "forum", "thread")
+		}
+	}
+
+	return check.Err()
+}
+
+// MarkSeen checks a mark-seen request; a zero lastSeen means the newest post.
+func MarkSeen(nickname string, lastSeen int64) error {
+	check := new(Checker)
+	check.Required("nickname", nickname)
+	if lastSeen < 0 {
+		check.fail("lastSeen", "must be a post id or 0")
+	}
+
+	return check.Err()
+}
+
+// Actor checks the user on whose behalf a moderation request is made.
+func Actor(actor string) error {
+	check := new(Checker)
+	check.Required("actor", actor)
+
+	return check.Err()
+}
+
+// RoleGrant only allows the roles that can be given; the owner is the
+// creator of the forum.
+func RoleGrant(role models.ForumRole) error {
+	check := new(Checker)
+	check.Required("nickname", role.Nickname)
+	check.OneOf("role", role.Role, models.RoleModerator, models.RoleMember)
+
+	return check.Err()
+}
+
+func Ban(ban models.ForumBan) error {
+	check := new(Checker)
+	check.Required("nickname", ban.Nickname)
+	check.MaxLength("reason", ban.Reason, MaxReason)
+
+	return check.Err()
+}
+
+func Report(report models.Report) error {
+	check := new(Checker)
+	check.OneOf("category", report.Category, models.CategorySpam, models.CategoryAbuse, models.CategoryOfftopic, models.CategoryIllegal, models.CategoryOther)
+	check.MaxLength("text", report.Text, MaxReason)
+
+	return check.Err()
+}
+
+func ReportStatus(status string) error {
+	check := new(Checker)
+	check.OneOf("status", status, models.ReportOpen, models.ReportResolved)
+
+	return check.Err()
+}
+
+func Resolution(resolution models.Resolution) error {
+	check := new(Checker)
+	check.OneOf("type", resolution.Type, models.ReportPost, models.ReportThread)
+	if resolution.Target <= 0 {
+		check.fail("target", "must be a positive id")
+	}
+	check.OneOf("action", resolution.Action, models.ResolveDismiss, models.ResolveDelete, models.ResolveBan)
+	check.MaxLength("note", resolution.Note, MaxReason)
+
+	return check.Err()
+}
+
+func Leaderboard(metric, period string) error {
+	check := new(Checker)
+	check.OneOf("metric", metric, models.MetricPosts, models.MetricThreads, models.MetricVotes)
+	check.OneOf("period", period, models.PeriodDay, models.PeriodWeek, models.PeriodMonth, models.PeriodAll)
+
+	return check.Err()
+}
+
+// RankedThreads checks the sort and period of a ranked thread list and that
+// since is a cursor returned for the same sort.
+func RankedThreads(sort, period, since string) error {
+	check := new(Checker)
+	check.OneOf("sort", sort, models.SortHot, models.SortTop, models.SortActive)
+	check.OneOf("period", period, models.PeriodDay, models.PeriodWeek, models.PeriodMonth, models.PeriodAll)
+
+	if since != "" {
+		valid := false
+		if separator := strings.LastIndex(since, ","); separator >= 0 {
+			value := since[:separator]
+			_, err := strconv.ParseInt(since[separator+1:], 10, 64)
+			valid = err == nil
+			switch sort {
+			case models.SortHot:
+				_, err = strconv.ParseFloat(value, 64)
+			case models.SortTop:
+				_, err = strconv.ParseInt(value, 10, 64)
+			case models.SortActive:
+				_, err = time.Parse(time.RFC3339Nano, value)
+			}
+			valid = valid && err == nil
+		}
+		if !valid {
+			check.fail("since", "must be a cursor from X-Next-Since of the same sort")
+		}
+	}
+
+	return check.Err()
+}
+
+// Activity checks a stats window of RFC 3339 dates.
+func Activity(from, to, bucket string) error {
+	check := new(Checker)
+	check.OneOf("bucket", bucket, models.BucketHour, models.BucketDay, models.BucketWeek)
+
+	start, startErr := time.Parse(time.RFC3339Nano, from)
+	if startErr != nil {
+		check.fail("from", "must be an RFC 3339 date-time")
+	}
+	end, endErr := time.Parse(time.RFC3339Nano, to)
+	if endErr != nil {
+		check.fail("to", "must be an RFC 3339 date-time")
+	}
+
+	if startErr == nil && endErr == nil {
+		if !start.Before(end) {
+			check.fail("from", "must be before to")
+		} else if size, ok := models.BucketSizes[bucket]; ok && end.Sub(start)/size >= MaxBuckets {
+			check.fail("bucket", "must split from..to into at most "+strconv.Itoa(MaxBuckets)+" buckets")
+		}
+	}
+
+	return check.Err()
+}
@@ -1,10 +1,32 @@
 DROP TABLE IF EXISTS users CASCADE;
 DROP TABLE IF EXISTS forums CASCADE;
 DROP TABLE IF EXISTS threads CASCADE;
-DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS messages CASCADE;
 DROP TABLE IF EXISTS voteThreads;
 DROP TABLE IF EXISTS forumUsers;
+DROP TABLE IF EXISTS webhookDeliveries;
+DROP TABLE IF EXISTS webhookOutbox;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS mentions;
+DROP TABLE IF EXISTS importedMessages;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS userStats;
+DROP TABLE IF EXISTS forumContributions;
+DROP TABLE IF EXISTS threadRanks;
+DROP TABLE IF EXISTS forumRoles;
+DROP TABLE IF EXISTS forumBans;
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS reportActions;
 DROP FUNCTION IF EXISTS updater;
+DROP FUNCTION IF EXISTS message_stats;
+DROP FUNCTION IF EXISTS thread_stats;
+DROP FUNCTION IF EXISTS forum_user_stats;
+DROP FUNCTION IF EXISTS user_stats_add;
+DROP FUNCTION IF EXISTS forum_totals;
+DROP FUNCTION IF EXISTS forum_rollup;
+DROP FUNCTION IF EXISTS forum_owner;
+DROP FUNCTION IF EXISTS rank_add;
 
 CREATE EXTENSION IF NOT EXISTS CITEXT;
 
@@ -14,7 +36,9 @@ CREATE UNLOGGED TABLE users
     nickname CITEXT COLLATE "C" UNIQUE,
     fullname VARCHAR(100) NOT NULL,
     email    CITEXT       NOT NULL UNIQUE,
-    about    TEXT
+    about    TEXT,
+    version  BIGINT DEFAULT 0,
+    auto_subscribe BOOLEAN NOT NULL DEFAULT false
 );
 
 CREATE INDEX idx_users_nickname ON users (email);
@@ -28,13 +52,18 @@ CREATE UNLOGGED TABLE forums
     title           TEXT,
     message_counter BIGINT DEFAULT 0,
     thread_counter  BIGINT DEFAULT 0,
-    u_nickname      CITEXT COLLATE "C" REFERENCES users (nickname) ON DELETE CASCADE
+    version         BIGINT DEFAULT 0,
+    u_nickname      CITEXT COLLATE "C" REFERENCES users (nickname) ON DELETE CASCADE,
+    parent          CITEXT REFERENCES forums (slug) ON DELETE SET NULL,
+    total_messages  BIGINT NOT NULL DEFAULT 0,
+    total_threads   BIGINT NOT NULL DEFAULT 0
 );
 
 CREATE INDEX idx_forums_slug ON forums (slug);
 CREATE INDEX idx_forums_slug_hash ON forums USING hash (slug);
 CLUSTER forums USING idx_forums_slug_hash;
 CREATE INDEX idx_forums_all ON forums (slug, title, u_nickname, message_counter, thread_counter);
+CREATE INDEX idx_forums_parent ON forums (parent);
 
 
 CREATE UNLOGGED TABLE threads
@@ -45,12 +74,17 @@ CREATE UNLOGGED TABLE threads
     message    TEXT,
     title      TEXT,
     votes      BIGINT DEFAULT 0,
+    version    BIGINT DEFAULT 0,
+    locked     BOOLEAN DEFAULT false,
+    tags       TEXT[]  NOT NULL DEFAULT '{}',
     u_nickname CITEXT COLLATE "C" NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
     f_slug     CITEXT COLLATE "C" NOT NULL REFERENCES forums (slug) ON DELETE CASCADE
 );
 
 
 CREATE INDEX idx_threads_fslugdate ON threads (f_slug, date);
+CREATE INDEX idx_threads_date ON threads (date);
+CREATE INDEX idx_threads_tags ON threads USING gin (tags);
 CLUSTER threads USING idx_threads_fslugdate;
 CREATE INDEX idx_threads_slug ON threads (slug);
 CREATE INDEX idx_threads_slughash ON threads USING hash (slug);
@@ -63,10 +97,12 @@ CREATE UNLOGGED TABLE voteThreads
     vt_id      BIGSERIAL,
     t_id       BIGINT             NOT NULL REFERENCES threads ON DELETE CASCADE,
     counter    INT DEFAULT 0,
+    date       TIMESTAMP WITH TIME ZONE DEFAULT now(),
     u_nickname CITEXT COLLATE "C" NOT NULL REFERENCES users (nickname) ON DELETE CASCADE
 );
 
 CREATE UNIQUE INDEX idx_voteth_thrnick ON voteThreads USING btree (t_id, u_nickname);
+CREATE INDEX idx_voteth_date ON voteThreads (date);
 
 CREATE UNLOGGED TABLE messages
 (
@@ -76,12 +112,15 @@ CREATE UNLOGGED TABLE messages
     edit       BOOLEAN DEFAULT false,
     parent     BIGINT,
     path       BIGINT[],
+    version    BIGINT DEFAULT 0,
     u_nickname CITEXT COLLATE "C" NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
     f_slug     CITEXT COLLATE "C" NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
     t_id       BIGINT             NOT NULL REFERENCES threads ON DELETE CASCADE
 );
 
 CREATE INDEX idx_messages_tid_mid ON messages (t_id, m_id);
+CREATE INDEX idx_messages_fslug_date ON messages (f_slug, date);
+CREATE INDEX idx_messages_date ON messages (date);
 CREATE INDEX idx_messages_parent_tree_tid_parent ON messages (t_id, m_id) WHERE parent = 0;
 CREATE INDEX idx_messages_path_1 ON messages (t_id, (path[1]), path);
 CLUSTER messages USING idx_messages_path_1;
@@ -92,12 +131,15 @@ CREATE INDEX idx_messages_all ON messages (m_id, date, message, edit, parent, u_
 CREATE UNLOGGED TABLE forumUsers
 (
     f_slug     CITEXT COLLATE "C" NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
-    u_nickname CITEXT COLLATE "C" NOT NULL REFERENCES users (nickname) ON DELETE CASCADE
+    u_nickname CITEXT COLLATE "C" NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    date       TIMESTAMP WITH TIME ZONE DEFAULT now()
 );
 
 CREATE UNIQUE INDEX idx_forumusers_slug_nick ON forumUsers (f_slug, u_nickname);
 CLUSTER forumUsers USING idx_forumusers_slug_nick;
 CREATE INDEX idx_forumusers_nick ON forumUsers (u_nickname);
+CREATE INDEX idx_forumusers_slug_date ON forumUsers (f_slug, date);
+CREATE INDEX idx_forumusers_nick_date ON forumUsers (u_nickname, date);
 
 CREATE OR REPLACE FUNCTION updater()
     RETURNS TRIGGER AS
@@ -127,4 +169,361 @@ CREATE TRIGGER u_updater
     BEFORE INSERT
     ON messages
     FOR EACH ROW
-    EXECUTE PROCEDURE updater();
\ No newline at end of file
+    EXECUTE PROCEDURE updater();
+
+CREATE UNLOGGED TABLE webhooks
+(
+    w_id    BIGSERIAL PRIMARY KEY,
+    f_slug  CITEXT COLLATE "C" NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    url     TEXT                     NOT NULL,
+    secret  TEXT                     NOT NULL,
+    events  TEXT[]                   NOT NULL,
+    date    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhooks_fslug ON webhooks (f_slug);
+
+CREATE UNLOGGED TABLE webhookOutbox
+(
+    o_id         BIGSERIAL PRIMARY KEY,
+    w_id         BIGINT                   NOT NULL REFERENCES webhooks ON DELETE CASCADE,
+    event        TEXT                     NOT NULL,
+    payload      JSONB                    NOT NULL,
+    status       TEXT                     NOT NULL DEFAULT 'pending',
+    attempts     INT                      NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    date         TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhookoutbox_pending ON webhookOutbox (next_attempt) WHERE status = 'pending';
+
+CREATE UNLOGGED TABLE webhookDeliveries
+(
+    d_id        BIGSERIAL PRIMARY KEY,
+    o_id        BIGINT                   NOT NULL REFERENCES webhookOutbox ON DELETE CASCADE,
+    w_id        BIGINT                   NOT NULL REFERENCES webhooks ON DELETE CASCADE,
+    attempt     INT                      NOT NULL,
+    status_code INT                      NOT NULL DEFAULT 0,
+    error       TEXT                     NOT NULL DEFAULT '',
+    duration_ms BIGINT                   NOT NULL DEFAULT 0,
+    date        TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhookdeliveries_wid ON webhookDeliveries (w_id, d_id);
+
+CREATE UNLOGGED TABLE notifications
+(
+    n_id       BIGSERIAL PRIMARY KEY,
+    u_nickname CITEXT COLLATE "C"       NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    kind       TEXT                     NOT NULL,
+    actor      CITEXT COLLATE "C"       NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    m_id       BIGINT                   NOT NULL REFERENCES messages ON DELETE CASCADE,
+    t_id       BIGINT                   NOT NULL REFERENCES threads ON DELETE CASCADE,
+    f_slug     CITEXT COLLATE "C"       NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    is_read    BOOLEAN                  NOT NULL DEFAULT false,
+    date       TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_notifications_nick_nid ON notifications (u_nickname, n_id);
+CREATE INDEX idx_notifications_nick_unread ON notifications (u_nickname, n_id) WHERE is_read = false;
+
+CREATE UNLOGGED TABLE mentions
+(
+    m_id       BIGINT             NOT NULL REFERENCES messages ON DELETE CASCADE,
+    u_nickname CITEXT COLLATE "C" NOT NULL REFERENCES users (nickname) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_mentions_mid_nick ON mentions (m_id, u_nickname);
+CREATE INDEX idx_mentions_nick_mid ON mentions (u_nickname, m_id);
+
+CREATE UNLOGGED TABLE importedMessages
+(
+    message_id TEXT               NOT NULL,
+    f_slug     CITEXT COLLATE "C" NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    t_id       BIGINT             NOT NULL REFERENCES threads ON DELETE CASCADE,
+    m_id       BIGINT             NOT NULL DEFAULT 0,
+    PRIMARY KEY (f_slug, message_id)
+);
+
+CREATE UNLOGGED TABLE subscriptions
+(
+    s_id       BIGSERIAL PRIMARY KEY,
+    u_nickname CITEXT COLLATE "C"       NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    t_id       BIGINT                   NOT NULL REFERENCES threads ON DELETE CASCADE,
+    last_seen  BIGINT                   NOT NULL DEFAULT 0,
+    date       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_subscriptions_nick_tid ON subscriptions (u_nickname, t_id);
+CREATE INDEX idx_subscriptions_tid ON subscriptions (t_id);
+
+CREATE UNLOGGED TABLE userStats
+(
+    u_nickname     CITEXT COLLATE "C" PRIMARY KEY REFERENCES users (nickname) ON DELETE CASCADE,
+    posts          BIGINT NOT NULL DEFAULT 0,
+    threads        BIGINT NOT NULL DEFAULT 0,
+    forums         BIGINT NOT NULL DEFAULT 0,
+    votes          BIGINT NOT NULL DEFAULT 0,
+    first_activity TIMESTAMP WITH TIME ZONE,
+    last_activity  TIMESTAMP WITH TIME ZONE
+);
+
+-- Statistics are kept up to date by the triggers below, so every write path
+-- (API, import, restore) counts the same way. Decrements only UPDATE: the
+-- row may already be gone when a user is deleted with everything they wrote.
+CREATE OR REPLACE FUNCTION user_stats_add(nick CITEXT, d_posts BIGINT, d_threads BIGINT, d_forums BIGINT, d_votes BIGINT, activity TIMESTAMP WITH TIME ZONE)
+    RETURNS VOID AS
+$BODY$
+BEGIN
+INSERT INTO userStats (u_nickname, posts, threads, forums, votes, first_activity, last_activity)
+VALUES (nick, d_posts, d_threads, d_forums, d_votes, activity, activity)
+    ON CONFLICT (u_nickname) DO UPDATE SET posts          = userStats.posts + EXCLUDED.posts,
+                                           threads        = userStats.threads + EXCLUDED.threads,
+                                           forums         = userStats.forums + EXCLUDED.forums,
+                                           votes          = userStats.votes + EXCLUDED.votes,
+                                           first_activity = LEAST(userStats.first_activity, EXCLUDED.first_activity),
+                                           last_activity  = GREATEST(userStats.last_activity, EXCLUDED.last_activity);
+END;
+$BODY$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION message_stats()
+    RETURNS TRIGGER AS
+$BODY$
+BEGIN
+    IF (TG_OP = 'INSERT') THEN
+        PERFORM user_stats_add(NEW.u_nickname, 1, 0, 0, 0, NEW.date);
+ELSE
+UPDATE userStats SET posts = posts - 1 WHERE u_nickname = OLD.u_nickname;
+END IF;
+RETURN NULL;
+END;
+$BODY$ LANGUAGE plpgsql;
+
+CREATE TRIGGER u_message_stats
+    AFTER INSERT OR DELETE
+    ON messages
+    FOR EACH ROW
+    EXECUTE PROCEDURE message_stats();
+
+CREATE OR REPLACE FUNCTION thread_stats()
+    RETURNS TRIGGER AS
+$BODY$
+BEGIN
+    IF (TG_OP = 'INSERT') THEN
+        PERFORM user_stats_add(NEW.u_nickname, 0, 1, 0, COALESCE(NEW.votes, 0), NEW.date);
+    ELSIF (TG_OP = 'UPDATE') THEN
+        PERFORM user_stats_add(NEW.u_nickname, 0, 0, 0, COALESCE(NEW.votes, 0) - COALESCE(OLD.votes, 0), NULL);
+ELSE
+UPDATE userStats SET threads = threads - 1 , votes = votes - COALESCE(OLD.votes, 0) WHERE u_nickname = OLD.u_nickname;
+END IF;
+RETURN NULL;
+END;
+$BODY$ LANGUAGE plpgsql;
+
+CREATE TRIGGER u_thread_stats
+    AFTER INSERT OR UPDATE OF votes OR DELETE
+    ON threads
+    FOR EACH ROW
+    EXECUTE PROCEDURE thread_stats();
+
+CREATE OR REPLACE FUNCTION forum_user_stats()
+    RETURNS TRIGGER AS
+$BODY$
+BEGIN
+    IF (TG_OP = 'INSERT') THEN
+        PERFORM user_stats_add(NEW.u_nickname, 0, 0, 1, 0, NULL);
+ELSE
+UPDATE userStats SET forums = forums - 1 WHERE u_nickname = OLD.u_nickname;
+END IF;
+RETURN NULL;
+END;
+$BODY$ LANGUAGE plpgsql;
+
+CREATE TRIGGER u_forum_user_stats
+    AFTER INSERT OR DELETE
+    ON forumUsers
+    FOR EACH ROW
+    EXECUTE PROCEDURE forum_user_stats();
+
+-- Daily per-forum rollup behind the leaderboards. It is written in the same
+-- transactions as forums.message_counter, so the posts of all days add up to it.
+CREATE UNLOGGED TABLE forumContributions
+(
+    f_slug     CITEXT COLLATE "C" NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    day        DATE               NOT NULL,
+    u_nickname CITEXT COLLATE "C" NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    posts      BIGINT             NOT NULL DEFAULT 0,
+    threads    BIGINT             NOT NULL DEFAULT 0,
+    votes      BIGINT             NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX idx_forumcontributions_slug_day_nick ON forumContributions (f_slug, day, u_nickname);
+
+-- Precomputed thread orderings for sort=hot|top|active. Posts, votes and
+-- deletes only move last_post and count up stale; the ranking worker rescores
+-- stale rows. hot and activity are logarithms that don't change with time,
+-- see app/repositories/ranking.go.
+CREATE UNLOGGED TABLE threadRanks
+(
+    t_id      BIGINT                   PRIMARY KEY REFERENCES threads ON DELETE CASCADE,
+    f_slug    CITEXT COLLATE "C"       NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    created   TIMESTAMP WITH TIME ZONE NOT NULL,
+    votes     BIGINT                   NOT NULL DEFAULT 0,
+    last_post TIMESTAMP WITH TIME ZONE NOT NULL,
+    activity  DOUBLE PRECISION,
+    hot       DOUBLE PRECISION         NOT NULL DEFAULT 0,
+    stale     BIGINT                   NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_threadranks_slug_hot ON threadRanks (f_slug, hot, t_id);
+CREATE INDEX idx_threadranks_hot ON threadRanks (hot, t_id);
+CREATE INDEX idx_threadranks_slug_votes ON threadRanks (f_slug, votes, t_id);
+CREATE INDEX idx_threadranks_votes ON threadRanks (votes, t_id);
+CREATE INDEX idx_threadranks_slug_lastpost ON threadRanks (f_slug, last_post, t_id);
+CREATE INDEX idx_threadranks_lastpost ON threadRanks (last_post, t_id);
+CREATE INDEX idx_threadranks_stale ON threadRanks (t_id) WHERE stale > 0;
+
+-- log2(2 ^ a + 2 ^ b) without overflow; NULL stands for an empty sum.
+CREATE OR REPLACE FUNCTION rank_add(a DOUBLE PRECISION, b DOUBLE PRECISION)
+    RETURNS DOUBLE PRECISION AS
+$BODY$
+SELECT CASE
+           WHEN a IS NULL THEN b
+           WHEN b IS NULL THEN a
+           ELSE GREATEST(a, b) + LN(1 + POWER(2, -ABS(a - b))) / LN(2)
+           END;
+$BODY$ LANGUAGE sql IMMUTABLE;
+
+-- total_messages and total_threads are the counters of a forum and all of its
+-- sub-forums. Changing the own counters moves the totals, and every change of
+-- the totals or of parent is passed on to the parent forum, which passes it
+-- on in turn.
+CREATE OR REPLACE FUNCTION forum_totals()
+    RETURNS TRIGGER AS
+$BODY$
+BEGIN
+    IF (TG_OP = 'INSERT') THEN
+        NEW.total_messages := COALESCE(NEW.message_counter, 0);
+        NEW.total_threads := COALESCE(NEW.thread_counter, 0);
+ELSE
+        NEW.total_messages := NEW.total_messages + COALESCE(NEW.message_counter, 0) - COALESCE(OLD.message_counter, 0);
+        NEW.total_threads := NEW.total_threads + COALESCE(NEW.thread_counter, 0) - COALESCE(OLD.thread_counter, 0);
+END IF;
+RETURN NEW;
+END;
+$BODY$ LANGUAGE plpgsql;
+
+CREATE TRIGGER u_forum_totals
+    BEFORE INSERT OR UPDATE
+    ON forums
+    FOR EACH ROW
+    EXECUTE PROCEDURE forum_totals();
+
+CREATE OR REPLACE FUNCTION forum_rollup()
+    RETURNS TRIGGER AS
+$BODY$
+BEGIN
+    IF (TG_OP = 'DELETE' OR OLD.parent IS DISTINCT FROM NEW.parent) THEN
+UPDATE forums SET total_messages = total_messages - OLD.total_messages , total_threads = total_threads - OLD.total_threads , version = version + 1
+WHERE slug = OLD.parent;
+END IF;
+
+    IF (TG_OP = 'UPDATE' AND OLD.parent IS DISTINCT FROM NEW.parent) THEN
+UPDATE forums SET total_messages = total_messages + NEW.total_messages , total_threads = total_threads + NEW.total_threads , version = version + 1
+WHERE slug = NEW.parent;
+    ELSIF (TG_OP = 'UPDATE' AND (OLD.total_messages <> NEW.total_messages OR OLD.total_threads <> NEW.total_threads)) THEN
+UPDATE forums SET total_messages = total_messages + NEW.total_messages - OLD.total_messages ,
+                  total_threads = total_threads + NEW.total_threads - OLD.total_threads , version = version + 1
+WHERE slug = NEW.parent;
+END IF;
+RETURN NULL;
+END;
+$BODY$ LANGUAGE plpgsql;
+
+CREATE TRIGGER u_forum_rollup
+    AFTER UPDATE OF message_counter, thread_counter, total_messages, total_threads, parent OR DELETE
+    ON forums
+    FOR EACH ROW
+    EXECUTE PROCEDURE forum_rollup();
+
+-- Roles of users in a forum. The creator gets owner from the trigger below on
+-- every write path; the rules for each role live in the permission service.
+CREATE UNLOGGED TABLE forumRoles
+(
+    f_slug     CITEXT COLLATE "C"       NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    u_nickname CITEXT COLLATE "C"       NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    role       TEXT                     NOT NULL CHECK (role IN ('owner', 'moderator', 'member')),
+    granted_by CITEXT COLLATE "C"       REFERENCES users (nickname) ON DELETE SET NULL,
+    date       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_forumroles_slug_nick ON forumRoles (f_slug, u_nickname);
+
+CREATE UNLOGGED TABLE forumBans
+(
+    f_slug     CITEXT COLLATE "C"       NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    u_nickname CITEXT COLLATE "C"       NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    moderator  CITEXT COLLATE "C"       REFERENCES users (nickname) ON DELETE SET NULL,
+    reason     TEXT                     NOT NULL DEFAULT '',
+    date       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_forumbans_slug_nick ON forumBans (f_slug, u_nickname);
+
+CREATE OR REPLACE FUNCTION forum_owner()
+    RETURNS TRIGGER AS
+$BODY$
+BEGIN
+    IF (NEW.u_nickname IS NOT NULL) THEN
+INSERT INTO forumRoles (f_slug, u_nickname, role) VALUES (NEW.slug, NEW.u_nickname, 'owner')
+    ON CONFLICT (f_slug, u_nickname) DO UPDATE SET role = 'owner';
+END IF;
+RETURN NULL;
+END;
+$BODY$ LANGUAGE plpgsql;
+
+CREATE TRIGGER u_forum_owner
+    AFTER INSERT
+    ON forums
+    FOR EACH ROW
+    EXECUTE PROCEDURE forum_owner();
+
+-- Resolutions of the moderation queue; kept after the content is deleted so
+-- the log shows who did what.
+CREATE UNLOGGED TABLE reportActions
+(
+    a_id      BIGSERIAL PRIMARY KEY,
+    f_slug    CITEXT COLLATE "C"       NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    kind      TEXT                     NOT NULL CHECK (kind IN ('post', 'thread')),
+    target    BIGINT                   NOT NULL,
+    action    TEXT                     NOT NULL CHECK (action IN ('dismiss', 'delete', 'ban')),
+    note      TEXT                     NOT NULL DEFAULT '',
+    moderator CITEXT COLLATE "C"       REFERENCES users (nickname) ON DELETE SET NULL,
+    author    CITEXT COLLATE "C",
+    reports   INT                      NOT NULL DEFAULT 0,
+    date      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_reportactions_slug_date ON reportActions (f_slug, date);
+
+-- Reports of posts and threads. target is the post or thread id and has no
+-- foreign key, so resolved reports outlive deleted content; a reporter has
+-- at most one open report per target.
+CREATE UNLOGGED TABLE reports
+(
+    r_id      BIGSERIAL PRIMARY KEY,
+    f_slug    CITEXT COLLATE "C"       NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    kind      TEXT                     NOT NULL CHECK (kind IN ('post', 'thread')),
+    target    BIGINT                   NOT NULL,
+    t_id      BIGINT                   NOT NULL,
+    author    CITEXT COLLATE "C",
+    reporter  CITEXT COLLATE "C"       NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    category  TEXT                     NOT NULL,
+    text      TEXT                     NOT NULL DEFAULT '',
+    status    TEXT                     NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    action_id BIGINT                   REFERENCES reportActions (a_id) ON DELETE SET NULL,
+    date      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_reports_open_reporter ON reports (kind, target, reporter) WHERE status = 'open';
+CREATE INDEX idx_reports_slug_status ON reports (f_slug, status, kind, target);
@@ -2,10 +2,16 @@ package main
 
 import (
 	"fmt"
+	"net"
+	"os"
 	"time"
 
 	"github.com/labstack/echo"
+	"google.golang.org/grpc"
+	"vk_db_project/app/commands"
+	"vk_db_project/app/graph"
 	"vk_db_project/app/handlers"
+	"vk_db_project/app/middleware"
 	repos "vk_db_project/app/repositories"
 	usecases "vk_db_project/app/uscases"
 
@@ -16,44 +22,81 @@ const (
 	usernameDB = "docker"
 	passwordDB = "docker"
 	nameDB     = "docker"
+
+	// Responses smaller than this are not worth compressing.
+	compressThreshold = 1024
+
+	// How stale sort=hot|top may get.
+	rankInterval = 30 * time.Second
 )
 
 type RequestHandler struct {
-	userHandler   handlers.UserHandler
-	forumHandler  handlers.ForumHandler
-	threadHandler handlers.ThreadHandler
-	postHandler   handlers.PostHandler
+	userHandler    handlers.UserHandler
+	forumHandler   handlers.ForumHandler
+	threadHandler  handlers.ThreadHandler
+	postHandler    handlers.PostHandler
+	hookHandler    handlers.WebhookHandler
+	hookWorker     usecases.WebhookUsecaseImpl
+	rankWorker     usecases.ForumUsecaseImpl
+	notifyHandler  handlers.NotificationHandler
+	mentionHandler handlers.MentionHandler
+	watchHandler   handlers.SubscriptionHandler
+	roleHandler    handlers.RoleHandler
+	reportHandler  handlers.ReportHandler
+	grpcHandler    *handlers.GrpcHandler
+	graphqlHandler handlers.GraphqlHandler
+	openapiHandler handlers.OpenapiHandler
 }
 
 func StartServer(db *pgx.ConnPool) *RequestHandler {
 
+	roleDB := repos.NewRoleRepoImpl(db)
+	permissions := usecases.NewPermissionServiceImpl(roleDB)
+	roleUse := usecases.NewRoleUsecaseImpl(roleDB, permissions)
+	roleH := handlers.NewRoleHandler(roleUse)
+
 	postDB := repos.NewPostRepoImpl(db)
-	postUse := usecases.NewPostUsecaseImpl(postDB)
+	postUse := usecases.NewPostUsecaseImpl(postDB, permissions)
 	postH := handlers.NewPostHandler(postUse)
 
 	threadDB := repos.NewThreadRepoImpl(db)
-	threadUse := usecases.NewThreadsUsecaseImpl(threadDB)
+	threadUse := usecases.NewThreadsUsecaseImpl(threadDB, permissions)
 	threadH := handlers.NewThreadHandler(threadUse)
 
+	reportDB := repos.NewReportRepoImpl(db)
+	reportUse := usecases.NewReportUsecaseImpl(reportDB, threadDB, permissions)
+	reportH := handlers.NewReportHandler(reportUse)
+
 	forumDB := repos.NewForumRepoImpl(db)
-	forumUse := usecases.NewForumUsecaseImpl(forumDB)
+	forumUse := usecases.NewForumUsecaseImpl(forumDB, permissions)
 	forumH := handlers.NewForumHandler(forumUse)
 
 	userDB := repos.NewUserRepoImpl(db)
 	userUse := usecases.NewUserUsecaseImpl(userDB)
 	userH := handlers.NewUserHandler(userUse)
 
-	api := &RequestHandler{userHandler: userH, forumHandler: forumH, threadHandler: threadH, postHandler: postH}
+	hookDB := repos.NewWebhookRepoImpl(db)
+	hookUse := usecases.NewWebhookUsecaseImpl(hookDB, permissions)
+	hookH := handlers.NewWebhookHandler(hookUse)
 
-	return api
-}
+	notifyDB := repos.NewNotificationRepoImpl(db)
+	notifyUse := usecases.NewNotificationUsecaseImpl(notifyDB)
+	notifyH := handlers.NewNotificationHandler(notifyUse)
 
-func JSONMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	mentionDB := repos.NewMentionRepoImpl(db)
+	mentionUse := usecases.NewMentionUsecaseImpl(mentionDB)
+	mentionH := handlers.NewMentionHandler(mentionUse)
 
-	return func(c echo.Context) error {
-		c.Response().Header().Set("Content-Type", "application/json; charset=utf-8")
-		return next(c)
-	}
+	subscribeDB := repos.NewSubscriptionRepoImpl(db)
+	subscribeUse := usecases.NewSubscriptionUsecaseImpl(subscribeDB, threadDB, permissions)
+	subscribeH := handlers.NewSubscriptionHandler(subscribeUse)
+
+	api := &RequestHandler{userHandler: userH, forumHandler: forumH, threadHandler: threadH, postHandler: postH, hookHandler: hookH, hookWorker: hookUse, rankWorker: forumUse,
+		notifyHandler: notifyH, mentionHandler: mentionH, watchHandler: subscribeH, roleHandler: roleH, reportHandler: reportH, grpcHandler: handlers.NewGrpcHandler(userUse, forumUse, threadUse, postUse),
+		graphqlHandler: handlers.NewGraphqlHandler(graph.NewResolver(userUse, forumUse, threadUse, postUse)),
+		openapiHandler: handlers.NewOpenapiHandler()}
+
+	return api
 }
 
 func Logs(next echo.HandlerFunc) echo.HandlerFunc {
@@ -79,16 +122,14 @@ func Logs(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
-func main() {
-	server := echo.New()
+func connectDB() (*pgx.ConnPool, error) {
 	connectString := "user=" + usernameDB + " password=" + passwordDB + " dbname=" + nameDB + " sslmode=disable"
 
 	pgxConn, err := pgx.ParseConnectionString(connectString)
-	pgxConn.PreferSimpleProtocol = false
-
 	if err != nil {
-		server.Logger.Fatal("PARSING CONFIG ERROR", err.Error())
+		return nil, err
 	}
+	pgxConn.PreferSimpleProtocol = false
 
 	config := pgx.ConnPoolConfig{
 		ConnConfig:     pgxConn,
@@ -97,17 +138,49 @@ func main() {
 		AcquireTimeout: 0,
 	}
 
-	connPool, err := pgx.NewConnPool(config)
-	defer connPool.Close()
+	return pgx.NewConnPool(config)
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		os.Exit(commands.Run(os.Args[1:], connectDB))
+	}
+
+	server := echo.New()
+
+	connPool, err := connectDB()
 	if err != nil {
 		server.Logger.Fatal("NO CONNECTION TO BD", err.Error())
 	}
+	defer connPool.Close()
+
 	fmt.Println(connPool.Stat())
 	api := StartServer(connPool)
+	server.Use(middleware.Compress(compressThreshold))
+	server.Use(middleware.Negotiate)
+	api.openapiHandler.SetupHandlers(server)
 	api.userHandler.SetupHandlers(server)
 	api.forumHandler.SetupHandlers(server)
 	api.threadHandler.SetupHandlers(server)
 	api.postHandler.SetupHandlers(server)
+	api.hookHandler.SetupHandlers(server)
+	api.notifyHandler.SetupHandlers(server)
+	api.mentionHandler.SetupHandlers(server)
+	api.watchHandler.SetupHandlers(server)
+	api.roleHandler.SetupHandlers(server)
+	api.reportHandler.SetupHandlers(server)
+	api.graphqlHandler.SetupHandlers(server)
+
+	go api.hookWorker.RunDeliveryWorker(time.Second)
+	go api.rankWorker.RunRankingWorker(rankInterval)
+
+	grpcServer := grpc.NewServer()
+	api.grpcHandler.SetupHandlers(grpcServer)
+	listener, err := net.Listen("tcp", ":5001")
+	if err != nil {
+		server.Logger.Fatal("CAN'T LISTEN GRPC PORT", err.Error())
+	}
+	go grpcServer.Serve(listener)
 
 	server.Logger.Fatal(server.Start(":5000"))
 }